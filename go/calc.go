@@ -8,7 +8,10 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
+
+	"calc/rpn"
 )
 
 // Color utility functions for terminal output
@@ -20,48 +23,16 @@ func yellow(text string) string {
 	return fmt.Sprintf("\033[33m%s\033[0m", text)
 }
 
-func red(text string) string {
-	return fmt.Sprintf("\033[31m%s\033[0m", text)
-}
-
 func blue(text string) string {
 	return fmt.Sprintf("\033[34m%s\033[0m", text)
 }
 
 func die(format string, args ...interface{}) {
 	message := fmt.Sprintf(format, args...)
-	fmt.Fprintf(os.Stderr, "%s\n", red(message))
+	fmt.Fprintf(os.Stderr, "%s\n", rpn.Red(message))
 	os.Exit(1)
 }
 
-type Aliases map[string]string
-
-func unalias(aliases Aliases, input string) string {
-	if name, ok := aliases[input]; ok {
-		return name
-	}
-	return input
-}
-
-var CONSTANTS = map[string]Value{
-	"e": { // e = 2.718281828459045235
-		number: newRationalNumber(2_718_281_828_459_045_235, 1_000_000_000_000_000_000),
-	},
-	"pi": {
-		number: Pi,
-	},
-	"G": { // g = 9.80665 m/s²
-		number: newRationalNumber(980_665, 100_000),
-		units: Unit{Length: UnitPower{BaseUnit{name: "m", dimension: Length, factor: newNumber(1)}, 1},
-			Time: UnitPower{BaseUnit{name: "s", dimension: Time, factor: newNumber(1)}, -2}},
-	},
-	"c": { // c = 299,792,458 m/s
-		number: newNumber(299_792_458),
-		units: Unit{Length: UnitPower{BaseUnit{name: "m", dimension: Length, factor: newNumber(1)}, 1},
-			Time: UnitPower{BaseUnit{name: "s", dimension: Time, factor: newNumber(1)}, -1}},
-	},
-}
-
 // readStdinValues reads lines from stdin and extracts values
 func readStdinValues() []string {
 	var values []string
@@ -107,8 +78,115 @@ func readStdinValues() []string {
 	return values
 }
 
+// display shows the stack as selected by the command-line options
+func display(stack *rpn.Stack, opts *rpn.Options) {
+	if options.json {
+		if err := stack.PrintJSON(os.Stdout, opts); err != nil {
+			die("Error: %v, exiting", err)
+		}
+		return
+	}
+
+	if options.showStats {
+		stack.PrintStats(os.Stdout, opts)
+	} else if options.oneline {
+		fmt.Println(stack.Oneline(opts))
+	} else {
+		stack.Print(os.Stdout, opts)
+	}
+
+	if options.registers {
+		stack.PrintRegisters(os.Stdout, opts)
+	}
+}
+
+// newEvaluator returns an evaluator with the units, definitions and options of the config files,
+// overridden by the options in args, and any macros defined in the macros file; it returns the other arguments
+func newEvaluator(args []string) (*rpn.Evaluator, []string) {
+	evaluator := rpn.NewEvaluator(options.Options)
+	evaluator.Quote = getStockQuoteFromCache
+	evaluator.Currency = convertCurrency
+
+	// Units from the units file, then defaults from the config file (which may use them), overridden by any flags
+	if err := loadUnitsFile(evaluator); err != nil {
+		die("Error: %v, exiting", err)
+	}
+	if err := loadConfig(evaluator); err != nil {
+		die("Error: %v, exiting", err)
+	}
+	args = scanOptions(args)
+	evaluator.Options = options.Options
+
+	if err := loadMacros(evaluator); err != nil {
+		die("Error: %v, exiting", err)
+	}
+	return evaluator, args
+}
+
+// loadMacros evaluates ~/.config/calc/macros, if present, before any other input
+// Text after '#' on a line is a comment
+func loadMacros(evaluator *rpn.Evaluator) error {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+
+	path := filepath.Join(homeDir, ".config", "calc", "macros")
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	// Macro definitions are always RPN, and the file holds nothing else
+	if err := evaluator.Define(lines); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+// repl runs an interactive read-eval-print loop with evaluator, keeping one stack between lines
+func repl(evaluator *rpn.Evaluator) {
+	scanner := bufio.NewScanner(os.Stdin)
+
+	for {
+		fmt.Print("> ")
+		if !scanner.Scan() {
+			fmt.Println()
+			break
+		}
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "q" || line == "quit" || line == "exit" {
+			break
+		}
+
+		preFetchStockQuotes([]string{line})
+		stack, err := evaluator.Eval([]string{line})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", rpn.Red(fmt.Sprintf("Error: %v", err)))
+		}
+		display(stack, &evaluator.Options)
+	}
+
+	if err := scanner.Err(); err != nil {
+		die("Error reading stdin: %v", err)
+	}
+}
+
 func main() {
-	// TODO: maybe keep history and print where error occurred
 	defer func() {
 		if r := recover(); r != nil {
 			die("Error: %v, exiting", r)
@@ -118,7 +196,7 @@ func main() {
 	// Ensure database is cleaned up on exit
 	defer closeDatabase()
 
-	args := scanOptions(os.Args[1:])
+	evaluator, args := newEvaluator(os.Args[1:])
 
 	// Check if we should read from stdin
 	stdinAvailable := false
@@ -126,16 +204,12 @@ func main() {
 		stdinAvailable = (stat.Mode() & os.ModeCharDevice) == 0
 	}
 
-	// If no arguments and no stdin, show usage
+	// If no arguments and stdin is a terminal, run interactively
 	if len(args) == 0 && !stdinAvailable {
-		usage()
-		os.Exit(1)
+		repl(evaluator)
+		return
 	}
 
-	generatePrefixedUnits()
-
-	stack := newStack()
-
 	// Read from stdin first if available
 	var stdinValues []string
 	if stdinAvailable {
@@ -149,62 +223,12 @@ func main() {
 	preFetchStockQuotes(allArgs)
 
 	// Process all arguments
-	for _, arg := range allArgs {
-		parts := strings.Fields(arg)
-		for _, part := range parts {
-			if options.trace {
-				fmt.Printf("[%s] %s\n", stack.oneline(), part)
-			}
-			if num, ok := parseNumber(part); ok {
-				stack.push(Value{number: num})
-			} else if base60, ok := parseBase60(part); ok {
-				// Base-60 input with ':' - just a regular number
-				stack.push(Value{number: base60})
-			} else if ipv4, ok := parseIPv4(part); ok {
-				// IPv4 address input - convert to integer
-				stack.push(Value{number: ipv4})
-			} else if constant, ok := CONSTANTS[part]; ok {
-				stack.push(constant)
-			} else if units, ok := parseUnits(part); ok {
-				stack.apply(units)
-			} else if stackOp, ok := STACKOP[unalias(STACKALIAS, part)]; ok {
-				stackOp(stack)
-			} else if ticker, ok := isTickerSymbol(part); ok {
-				// Stock ticker symbol (@aapl, @wday, etc.)
-				// Use pre-fetched quote if available
-				value, err := getStockQuoteFromCache(ticker)
-				if err != nil {
-					die("Failed to get quote for '%s': %v", ticker, err)
-				}
-				stack.push(value)
-			} else if strings.HasPrefix(part, "@") && len(part) > 1 {
-				// Stack reduction operation (@+, @*, etc.)
-				opName := unalias(OPALIAS, part[1:])
-				if operator, ok := OPERATOR[opName]; ok && !operator.unary {
-					stack.reduce(opName)
-				} else {
-					die("Invalid reduction operation '%s', exiting", part)
-				}
-			} else if operator, ok := OPERATOR[unalias(OPALIAS, part)]; ok {
-				if operator.unary {
-					stack.unaryOp(unalias(OPALIAS, part))
-				} else {
-					stack.binaryOp(unalias(OPALIAS, part))
-				}
-			} else {
-				die("Unrecognized argument '%s', exiting", part)
-			}
-		}
+	stack, err := evaluator.Eval(allArgs)
+	if err != nil {
+		die("Error: %v, exiting", err)
 	}
 
-	// Show statistics if requested
-	if options.showStats {
-		stack.printStats()
-	} else if options.oneline {
-		fmt.Println(stack.oneline())
-	} else {
-		stack.print()
-	}
+	display(stack, &evaluator.Options)
 
 	// Show detailed stock quote information if requested
 	if options.detail {
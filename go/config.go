@@ -0,0 +1,233 @@
+// Copyright 2024 Mike Carlton
+// Released under terms of the MIT License:
+//   http://www.opensource.org/licenses/mit-license.php
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"calc/rpn"
+)
+
+// The config file is a small subset of TOML: [section] headers and key = value lines,
+// with '#' comments; keys and string values may be quoted, e.g.
+//
+//	[options]
+//	precision = 6
+//	group = true
+//
+//	[opalias]
+//	"×" = "*"
+//
+//	[stackalias]
+//	swap = "x"
+//
+//	[units]
+//	furlong = "201.168 m"
+//
+//	[constants]
+//	h = "6.62607015e-34 J·s"
+//
+//	[preferred]
+//	J = "kWh"
+//
+// Units and constants are RPN expressions; [preferred] displays values with the dimensions
+// of each key in the unit given, e.g. energy in kWh. Command-line flags are applied after the file.
+
+func getConfigFile() (string, error) {
+	return getConfigPath("config")
+}
+
+// getConfigPath returns the path of file in ~/.config/calc
+func getConfigPath(file string) (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(homeDir, ".config", "calc", file), nil
+}
+
+// loadUnitsFile adds the units defined in ~/.config/calc/units, if present, to evaluator (see rpn.Evaluator.LoadUnits)
+func loadUnitsFile(evaluator *rpn.Evaluator) error {
+	path, err := getConfigPath("units")
+	if err != nil {
+		return nil
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return evaluator.LoadUnits(file, path)
+}
+
+// loadConfig applies ~/.config/calc/config, if present, to the options and the definitions of evaluator
+func loadConfig(evaluator *rpn.Evaluator) error {
+	path, err := getConfigFile()
+	if err != nil {
+		return nil
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	section := ""
+	lineNumber := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			name, ok := strings.CutSuffix(strings.TrimSpace(stripComment(line)), "]")
+			if !ok {
+				return fmt.Errorf("%s:%d: invalid section header '%s'", path, lineNumber, line)
+			}
+			section = strings.TrimSpace(name[1:])
+			continue
+		}
+
+		key, value, err := parseConfigLine(line)
+		if err == nil {
+			err = applyConfig(evaluator, section, key, value)
+		}
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", path, lineNumber, err)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// parseConfigLine splits key = value, removing quotes and any trailing comment
+func parseConfigLine(line string) (string, string, error) {
+	key, value, ok := strings.Cut(line, "=")
+	if !ok {
+		return "", "", fmt.Errorf("expected key = value, found '%s'", line)
+	}
+
+	key, err := unquote(strings.TrimSpace(key))
+	if err != nil {
+		return "", "", err
+	}
+
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, `"`) {
+		end := strings.Index(value[1:], `"`)
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated string for '%s'", key)
+		}
+		if rest := strings.TrimSpace(value[end+2:]); rest != "" && !strings.HasPrefix(rest, "#") {
+			return "", "", fmt.Errorf("unexpected '%s' after value for '%s'", rest, key)
+		}
+		return key, value[1 : end+1], nil
+	}
+
+	return key, strings.TrimSpace(stripComment(value)), nil
+}
+
+func stripComment(text string) string {
+	text, _, _ = strings.Cut(text, "#")
+	return text
+}
+
+func unquote(text string) (string, error) {
+	if strings.HasPrefix(text, `"`) {
+		if len(text) < 2 || !strings.HasSuffix(text, `"`) {
+			return "", fmt.Errorf("unterminated string '%s'", text)
+		}
+		return text[1 : len(text)-1], nil
+	}
+	return text, nil
+}
+
+// configFlags maps boolean option names in the [options] section to the options they set
+var configFlags = map[string]*bool{
+	"superscript": &options.Superscript,
+	"group":       &options.Group,
+	"base":        &options.Base,
+	"binary":      &options.ShowBinary,
+	"hex":         &options.ShowHex,
+	"hexfloat":    &options.ShowHexFloat,
+	"octal":       &options.ShowOctal,
+	"ipv4":        &options.ShowIPv4,
+	"rational":    &options.ShowRational,
+	"factor":      &options.ShowFactor,
+	"polar":       &options.Polar,
+	"infix":       &options.Infix,
+	"auto-prefix": &options.AutoPrefix,
+	"ieee32":      &options.ShowIEEE32,
+	"ieee64":      &options.ShowIEEE64,
+	"trace":       &options.Trace,
+	"debug":       &options.Debug,
+	"oneline":     &options.oneline,
+	"stats":       &options.showStats,
+	"registers":   &options.registers,
+	"json":        &options.json,
+	"detail":      &options.detail,
+	"extended":    &options.extended,
+}
+
+func applyConfig(evaluator *rpn.Evaluator, section, key, value string) error {
+	switch section {
+	case "options":
+		if flag, ok := configFlags[key]; ok {
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("boolean value required for '%s', cannot parse '%s'", key, value)
+			}
+			*flag = enabled
+			return nil
+		}
+
+		switch key {
+		case "precision", "column":
+			number, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("integer value required for '%s', cannot parse '%s'", key, value)
+			}
+			if key == "precision" {
+				options.Precision = number
+			} else {
+				options.column = number
+			}
+		case "date":
+			options.date = value
+		default:
+			return fmt.Errorf("unknown option '%s'", key)
+		}
+	case "opalias":
+		return evaluator.AliasOperator(key, value)
+	case "stackalias":
+		return evaluator.AliasStackOperation(key, value)
+	case "units":
+		return evaluator.DefineUnit(key, key, value)
+	case "constants":
+		return evaluator.DefineConstant(key, value)
+	case "preferred":
+		return evaluator.PreferUnit(key, value)
+	default:
+		return fmt.Errorf("unknown section '[%s]'", section)
+	}
+
+	return nil
+}
@@ -16,6 +16,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"calc/rpn"
 )
 
 // OpenExchangeRates API schema
@@ -209,7 +211,7 @@ func getRates() (*ExchangeRates, error) {
 }
 
 // convertCurrency converts a Number from one currency to another
-func convertCurrency(amount *Number, from, to string) (*Number, error) {
+func convertCurrency(amount *rpn.Number, from, to string) (*rpn.Number, error) {
 	rates, err := getRates()
 	if err != nil {
 		return nil, err
@@ -235,36 +237,15 @@ func convertCurrency(amount *Number, from, to string) (*Number, error) {
 	if fromCurrency == rates.Base {
 		// Converting from USD to target currency: amount * rate
 		rate := rates.Rates[toCurrency]
-		rateNumber := newNumber(strconv.FormatFloat(rate, 'f', -1, 64))
-		return mul(amount, rateNumber), nil
+		rateNumber := new(rpn.Number).Set(strconv.FormatFloat(rate, 'f', -1, 64))
+		return new(rpn.Number).Mul(amount, rateNumber), nil
 	} else if toCurrency == rates.Base {
 		// Converting from source currency to USD: amount / rate
 		rate := rates.Rates[fromCurrency]
-		rateNumber := newNumber(strconv.FormatFloat(rate, 'f', -1, 64))
-		return div(amount, rateNumber), nil
+		rateNumber := new(rpn.Number).Set(strconv.FormatFloat(rate, 'f', -1, 64))
+		return new(rpn.Number).Quo(amount, rateNumber), nil
 	} else {
 		// This should be handled by the unit system for non-USD to non-USD conversions
 		return nil, fmt.Errorf("invalid usage: convert %s -> %s (must go through USD)", fromCurrency, toCurrency)
 	}
 }
-
-// Supported currency codes
-var supportedCurrencies = map[string]string{
-	"usd": "USD",
-	"$":   "USD",
-	"eur": "EUR",
-	"€":   "EUR",
-	"gbp": "GBP",
-	"£":   "GBP",
-	"yen": "JPY",
-	"jpy": "JPY",
-	"¥":   "JPY",
-	"btc": "BTC",
-}
-
-// getCurrencyCode normalizes currency symbols to standard codes
-func getCurrencyCode(symbol string) (string, bool) {
-	code, exists := supportedCurrencies[strings.ToLower(symbol)]
-	return code, exists
-}
-
@@ -2,4 +2,4 @@ module calc
 
 go 1.24.0
 
-require github.com/mattn/go-sqlite3 v1.14.32 // indirect
+require github.com/mattn/go-sqlite3 v1.14.32
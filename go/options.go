@@ -9,35 +9,25 @@ import (
 	"os"
 	"strconv"
 	"strings"
+
+	"calc/rpn"
 )
 
+// Options holds the command-line options; display and parsing options are passed on to the evaluator
 type Options struct {
-	base         bool
-	column       int
-	debug        bool
-	date         string
-	detail       bool
-	extended     bool
-	group        bool
-	oneline      bool
-	precision    int
-	showBinary   bool
-	showHex      bool
-	showHexFloat bool
-	showOctal    bool
-	showIPv4     bool
-	showIEEE32   bool
-	showIEEE64   bool
-	showRational bool
-	showFactor   bool
-	showStats    bool
-	superscript  bool
-	trace        bool
+	rpn.Options
+	column    int
+	date      string
+	detail    bool
+	extended  bool
+	oneline   bool
+	showStats bool
+	registers bool
+	json      bool
 }
 
 var options = Options{
-	precision:   4,
-	superscript: true, // Default to using superscript
+	Options: rpn.DefaultOptions(),
 }
 
 func heredoc(text string) string {
@@ -68,6 +58,7 @@ func heredoc(text string) string {
 func usage() {
 	fmt.Printf("%s\n", heredoc(fmt.Sprintf(`
         Usage: [ ARGUMENTS | ] calc [OPTIONS | ARGUMENTS]
+               calc [OPTIONS]   (interactive, when stdin is a terminal; q to quit)
         Options:
           -b         Show binary representation of integers
           -o         Show octal representation of integers
@@ -79,9 +70,13 @@ func usage() {
           -g         Use ',' to group decimal numbers, '_' to group other bases
           -s         Show statistics summary
           -O         Show final stack on one line
+          -v         Show named registers after the stack
+          --json     Show the final stack and registers as JSON (exact numerator/denominator, decimal, units,
+                     dimensions, and the -x, -o, -b, -i and -f representations when selected)
           -S         Disable superscript powers (use ^ notation instead)
           -c Integer Column to extract from lines on stdin (negative counts from end)
           -p Integer Set display precision for floating point number (default: %d)
+                     sqrt, logs and non-integer powers are computed to at least this many digits
           -D Date    Date for currency conversion rates (e.g. 2022-01-01)
           -d         Show detailed information for stock quotes used in calculations
           -e         Request extended hours (pre-market/post-market) stock quotes
@@ -90,8 +85,13 @@ func usage() {
           --ieee64   Show IEEE 754 64-bit float representation (sign|exponent|mantissa in hex; binary with -b)
           --debug    Show debug information
           --base     Display units as base units only (no derived units)
+          --polar    Display complex numbers in polar form (magnitude∠angle in degrees)
+          --auto-prefix  Display values in SI units with the prefix that puts the mantissa in [1, 1000),
+                     e.g. 0.000047 A as 47 μA (other units such as ft, gal and usd are unchanged)
+          --infix    Parse each argument as an infix expression, e.g. '(3 ft + 2 in) * 4 in m'
+                     (arguments with parentheses outside of units, as in J/(kg·K), are always infix)
           -h         Show extended help
-    `, options.precision)))
+    `, options.Precision)))
 }
 
 func doHelp() {
@@ -103,6 +103,8 @@ func doHelp() {
           e
           c (speed of light)
           G (Earth's gravitational constant)
+          NA (Avogadro constant, /mol)
+          R (gas constant, J/K·mol)
     `))
 
 	fmt.Printf("%s\n", heredoc(`
@@ -119,6 +121,12 @@ func doHelp() {
 
           Numbers can have a final binary magnitude factor (KMGTPEZY) for
           kilo-, mega-, giga-, tera-, peta-, exa-, zetta- or yotta-byte
+
+          Numbers can have attached units: 5kg, 3.5ft, 12V, 1.5hr (the same as 5 kg, etc.)
+            A magnitude factor that starts valid units is read as a prefix: 4Mm is 4 megameters and 4MiB is 4 mebibytes, while 4M is the number 4·2²⁰
+            Units of one family, largest first, add up: 5ft11in or 5'11" is 5 ft 11 in, 2lb3oz is 2 lb 3 oz
+
+          Complex numbers: 3+4i, 2.5-1j, -2i, i or j (exact rational real and imaginary parts)
     `))
 
 	fmt.Printf("%s\n", heredoc(`
@@ -132,6 +140,45 @@ func doHelp() {
           max:  push maximum value onto stack
           mean: push mean (average) value onto stack
           size: push stack size onto stack
+
+        Registers (names start with a letter; values keep their units):
+          >name:  pop top element into register name
+          <name:  push value of register name
+          >:name: clear register name
+
+        Macros:
+          : name tokens ;  define name to apply tokens (e.g. : circ d * pi * ;)
+          Macros may use operators, stack operations, @ reductions and other macros,
+          but not share a name with a unit, operator, stack operation or constant
+          Definitions in ~/.config/calc/macros are loaded at startup ('#' starts a comment);
+          the file may only contain definitions
+
+        Infix expressions (with --infix, or any argument with parentheses outside of units):
+          calc '(3 ft + 2 in) * 4 in m'  is  calc 3 ft 2 in + 4 in m *
+          Precedence, lowest first: |  ^  &  << >>  + -  * / %  unary - ~  **  postfix ! and units
+          Units apply to the value before them: use parentheses to convert a result, ((1 + 2) m) ft
+          Functions are the named operators: sqrt(2), log10(x), atan2(1, 2), pow(2, 10)
+          ',' separates function arguments, so group digits with '_' (1_000)
+    `))
+
+	fmt.Printf("%s\n", heredoc(`
+        Configuration (~/.config/calc/config, command-line flags take precedence):
+          [options]      precision = 6, group = true, superscript = false, base = true, ...
+                         (also binary, hex, hexfloat, octal, ipv4, rational, factor, ieee32, ieee64,
+                          polar, infix, auto-prefix, trace, debug, oneline, stats, registers, json, detail, extended, column, date)
+          [opalias]      "×" = "*"
+          [stackalias]   swap = "x"
+          [units]        furlong = "201.168 m"
+          [constants]    h = "6.62607015e-34 J·s"
+          [preferred]    J = "kWh"   (display values with the dimensions of J in kWh)
+          Units and constants are RPN expressions; '#' starts a comment
+
+        Units file (~/.config/calc/units, loaded before the configuration), one unit per line:
+          furlong = 660 ft
+          U = 1.75 in          # definitions are RPN expressions and may use units from anywhere in the file
+          sprint* = 2 wk       # '*' adds SI prefixes (ksprint)
+          request* !           # '!' adds a dimension with this base unit ($/request, krequest/s)
+          Cycles, repeated names and names that are already units, operators or constants are errors
     `))
 
 	fmt.Printf("%s\n", heredoc(`
@@ -139,7 +186,8 @@ func doHelp() {
           + - /
           *   (aliased as . and •)
           %   (modulo, dimensionless values only)
-          **  (aliased as pow, power must be dimensionless)
+          **  (aliased as pow, power must be dimensionless; units take rational powers: 9 m²/s² 0.5 ** is 3 m/s)
+          nroot (n-th root: 16 m⁴ 4 nroot is 2 m)
 
         Unary numerical operations:
           num   (numeric: remove any units)
@@ -149,11 +197,27 @@ func doHelp() {
           log   (natural log)
           log10 (base 10 log)
           log2  (base 2 log)
-          sqrt  (square root, dimensionless values only)
+          sqrt  (square root, of units too: 4 m² sqrt is 2 m, 1 acre sqrt is 63.6149 m, but m has no square root)
+          cbrt  (cube root, real for negative values: -8 m³ cbrt is -2 m)
           rand  (random number in range [0, value))
           mask  (IPv4 mask)
           r     (reciprocal)
 
+        Trigonometric operations (angles in rad, deg, grad, arcmin or arcsec; plain numbers are radians):
+          sin cos tan
+          asin acos atan  (result in radians, e.g. 1 asin deg)
+          atan2           (binary: y x atan2, result in radians)
+
+        Hyperbolic operations (dimensionless values only):
+          sinh cosh tanh asinh acosh atanh
+
+        Complex operations (also accepted by + - * / ** chs r sqrt cbrt nroot log log10 log2):
+          abs   (magnitude)
+          arg   (angle, in radians)
+          conj  (conjugate)
+          re    (real part)
+          im    (imaginary part)
+
         Bitwise operations (integers only):
           &     (bitwise AND, prepend with '@' to reduce the stack)
           |     (bitwise OR, prepend with '@' to reduce the stack)
@@ -168,37 +232,82 @@ func doHelp() {
           Units are applied if current top of stack does not have any units
           Otherwise the current top of stack is converted to the units
 
-          SI prefixes are supported for all SI units (except ha):
+          SI prefixes are supported for all SI units, including derived units (except ha):
             da (deca, 10¹), h (hecto, 10²), k (kilo, 10³), M (mega, 10⁶),
             G (giga, 10⁹), T (tera, 10¹²), P (peta, 10¹⁵), E (exa, 10¹⁸),
 
             d (deci, 10⁻¹), c (centi, 10⁻²), m (milli, 10⁻³), μ or u (micro, 10⁻⁶),
             n (nano, 10⁻⁹), p (pico, 10⁻¹²), f (femto, 10⁻¹⁵), a (atto, 10⁻¹⁸),
+            A unit name always wins over a prefixed one, and products need a separator (m·s), so ms is milliseconds
 
           time
-            seconds (s), minutes (min), hours (hr)
+            seconds (s), minutes (min), hours (hr), days (day), weeks (wk), julian years (yr)
           length
             meters (m)
             inches (in), feet (ft), yards (yd), miles (mi)
+            nautical miles (nmi), astronomical units (au), light years (ly)
           area
             hectares (ha)
             acres (acre)
           volume
             liters (l)
             fl. ounces (foz), cups (cup), pints (pt), quarts (qt), us gallons (gal)
+            imperial pints (ukpt), imperial gallons (ukgal)
+            Areas and volumes are powers of length, so any combination converts:
+            1 acre m², 2 l/s ft³/min, 1 acre·ft gal, 1 ha 1 m * (10000 m³)
           mass
             grams (g)
-            ounces (oz), pounds (lb)
+            ounces (oz), pounds (lb), stone (st), metric tonnes (tonne)
+            (the symbol t is not a unit, as it is the truncate operator: 2 tonne, not 2 t)
           temperature
             celsius (C or °C), delta celsius (dC)
             fahrenheit (F or °F), delta fahrenheit (dF)
+            kelvin (K, with SI prefixes), rankine (°R), delta rankine (dR)
+            Differences of °C and °F readings are deltas; K and °R start at absolute zero, so need none.
+            In compound units (W/K, J/kg·K) temperatures are differences.
           current
             amperes (A)
+          amount of substance
+            moles (mol)
+          luminous intensity
+            candelas (cd), lumens (lm, the same dimension: steradians are dimensionless)
+          angle
+            radians (rad), degrees (deg), gradians (grad), arc-minutes (arcmin), arc-seconds (arcsec)
           currency
             euros (eur or €), gb pounds (gbp or £), yen (yen or ¥), bitcoin (btc), us dollars (usd or $)
+            Currencies convert within compound units and powers at the exchange rate: 3.50 $/gal €/l
+          information
+            bits (bit), bytes (B), bits per second (bps)
+            SI prefixes are decimal (kB = 1000 B, k to E only); binary prefixes Ki, Mi, Gi, Ti, Pi, Ei, Zi, Yi
+            are powers of 1024 (KiB = 1024 B). Magnitude suffixes (4G) remain plain numbers.
+            e.g. 4 GiB 100 Mbps / min  (time to copy 4 GiB at 100 Mbps)
 
           derived units
-            joules (J), newtons (N), ohms (Ω or ohm), volts (V), watts (W)
+            joules (J), newtons (N), ohms (Ω or ohm), volts (V), watts (W), pascals (Pa), hertz (Hz),
+            lux (lx), katals (kat)
+            Products of SI units display with derived units where that
+            removes base units: kg·m/s is N·s, kg·m²/s²·A is V·s, but m/s² stays as is
+          pressure
+            bars (bar, with SI prefixes), standard atmospheres (atm), pounds per square inch (psi),
+            millimeters of mercury (mmHg)
+          energy
+            calories (cal, kcal), british thermal units (BTU), watt-hours (Wh, kWh), electronvolts (eV, keV, MeV)
+          speed
+            miles per hour (mph), kilometers per hour (kph), knots (kn)
+          force
+            pounds-force (lbf), kilograms-force (kgf), dynes (dyn)
+          power
+            horsepower (hp)
+          frequency
+            revolutions per minute (rpm)
+
+          composite units (units of one family joined by '+', largest first)
+            show whole numbers of each unit and the rest in the last one, and count the largest
+            unit for a number given in them (1.5 ft+in is 1 ft 6 in); after other arithmetic,
+            values in them show in the last unit (5ft11in 2 s / is 35.5 in/s):
+            1.8 m ft+in (5 ft 10.8661 in), 2.3 kg lb+oz, 1e6 s d+hr+min+s (11 day 13 hr 46 min 40 s)
+            families: mi yd ft in, km m cm mm, st lb oz, kg g, yr wk day (d) hr (h) min s,
+            gal qt pt cup foz, ukgal ukpt, deg arcmin arcsec
     `))
 }
 
@@ -210,42 +319,52 @@ func scanOptions(args []string) []string {
 			doHelp()
 			os.Exit(1)
 		case "-t":
-			options.trace = true
+			options.Trace = true
 		case "-O":
 			options.oneline = true
 		case "-s":
 			options.showStats = true
+		case "-v":
+			options.registers = true
 		case "-S":
-			options.superscript = false
+			options.Superscript = false
 		case "-g":
-			options.group = true
+			options.Group = true
 		case "-d":
 			options.detail = true
 		case "-e":
 			options.extended = true
 		case "-x":
-			options.showHex = true
+			options.ShowHex = true
 		case "-X":
-			options.showHex = true
-			options.showHexFloat = true
+			options.ShowHex = true
+			options.ShowHexFloat = true
 		case "-o":
-			options.showOctal = true
+			options.ShowOctal = true
 		case "-b":
-			options.showBinary = true
+			options.ShowBinary = true
 		case "-i":
-			options.showIPv4 = true
+			options.ShowIPv4 = true
 		case "-r":
-			options.showRational = true
+			options.ShowRational = true
 		case "-f":
-			options.showFactor = true
+			options.ShowFactor = true
 		case "--ieee32":
-			options.showIEEE32 = true
+			options.ShowIEEE32 = true
 		case "--ieee64":
-			options.showIEEE64 = true
+			options.ShowIEEE64 = true
 		case "--debug":
-			options.debug = true
+			options.Debug = true
 		case "--base":
-			options.base = true
+			options.Base = true
+		case "--polar":
+			options.Polar = true
+		case "--auto-prefix":
+			options.AutoPrefix = true
+		case "--json":
+			options.json = true
+		case "--infix":
+			options.Infix = true
 		case "-c":
 			if i < len(args)-1 {
 				if column, err := strconv.Atoi(args[i+1]); err == nil {
@@ -270,7 +389,7 @@ func scanOptions(args []string) []string {
 		case "-p":
 			if i < len(args)-1 {
 				if precision, err := strconv.Atoi(args[i+1]); err == nil {
-					options.precision = precision
+					options.Precision = precision
 					consumed = 2
 				} else {
 					fmt.Fprintf(os.Stderr, "Integer argument required for '%s', cannot parse '%s', exiting\n", args[i], args[i+1])
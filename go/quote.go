@@ -10,10 +10,11 @@ import (
 	"io"
 	"net/http"
 	"os"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
+
+	"calc/rpn"
 )
 
 // FiftyTwoWeek represents the 52-week range data
@@ -46,10 +47,8 @@ type QuoteResponse struct {
 // BatchQuoteResponse is the response when fetching multiple quotes
 type BatchQuoteResponse map[string]QuoteResponse
 
-var tickerPattern = regexp.MustCompile(`^@([a-zA-Z]+)$`)
-
 // Global cache for pre-fetched quotes
-var preFetchedQuotes = make(map[string]Value)
+var preFetchedQuotes = make(map[string]rpn.Value)
 var preFetchedQuoteData = make(map[string]*QuoteResponse)
 var preFetchedQuoteTypeData = make(map[string]QuoteType)
 
@@ -57,15 +56,6 @@ var preFetchedQuoteTypeData = make(map[string]QuoteType)
 var usedQuotes = make(map[string]*QuoteResponse)
 var usedQuoteTypes = make(map[string]QuoteType)
 
-// isTickerSymbol checks if the input string is a ticker symbol (e.g., @aapl)
-func isTickerSymbol(input string) (string, bool) {
-	matches := tickerPattern.FindStringSubmatch(input)
-	if len(matches) == 2 {
-		return strings.ToUpper(matches[1]), true
-	}
-	return "", false
-}
-
 // preFetchStockQuotes scans all arguments and batch fetches stock quotes
 func preFetchStockQuotes(args []string) {
 	// Collect all unique ticker symbols
@@ -73,7 +63,7 @@ func preFetchStockQuotes(args []string) {
 	for _, arg := range args {
 		parts := strings.Fields(arg)
 		for _, part := range parts {
-			if ticker, ok := isTickerSymbol(part); ok {
+			if ticker, ok := rpn.IsTickerSymbol(part); ok {
 				symbolsMap[ticker] = true
 			}
 		}
@@ -176,7 +166,7 @@ func preFetchStockQuotes(args []string) {
 }
 
 // getStockQuoteFromCache retrieves a pre-fetched stock quote
-func getStockQuoteFromCache(symbol string) (Value, error) {
+func getStockQuoteFromCache(symbol string) (rpn.Value, error) {
 	value, ok := preFetchedQuotes[symbol]
 	if !ok {
 		// Fallback to individual fetch if not in cache
@@ -218,7 +208,7 @@ func fetchQuotes(symbols []string) (map[string]*QuoteResponse, error) {
 		symbolList, apiKey, extendedParam)
 
 	// Print URL in blue if debug mode is enabled
-	if options.debug {
+	if options.Debug {
 		fmt.Fprintf(os.Stderr, "%s\n", blue(url))
 	}
 
@@ -239,7 +229,7 @@ func fetchQuotes(symbols []string) (map[string]*QuoteResponse, error) {
 	}
 
 	// Print JSON response in green if debug mode is enabled
-	if options.debug {
+	if options.Debug {
 		fmt.Fprintf(os.Stderr, "%s\n", green(string(body)))
 	}
 
@@ -337,12 +327,12 @@ func determineQuoteType(quote *QuoteResponse) QuoteType {
 }
 
 // getStockQuote fetches a stock quote with caching and returns it as a Value
-func getStockQuote(symbol string) (Value, error) {
+func getStockQuote(symbol string) (rpn.Value, error) {
 	// Check if we should use cached data
 	if !shouldFetchQuote(symbol, options.extended) {
 		cached, err := getLatestQuote(symbol, QuoteTypeRegular)
 		if err == nil && cached != nil {
-			if options.debug || options.trace {
+			if options.Debug || options.Trace {
 				fmt.Fprintf(os.Stderr, "Using cached quote for %s from %s\n", symbol, cached.Date)
 			}
 
@@ -377,7 +367,7 @@ func getStockQuote(symbol string) (Value, error) {
 	// Fetch fresh quote
 	quote, err := fetchQuote(symbol)
 	if err != nil {
-		return Value{}, err
+		return rpn.Value{}, err
 	}
 
 	// Determine quote type
@@ -407,7 +397,7 @@ func getStockQuote(symbol string) (Value, error) {
 	}
 
 	// Display verbose quote information if requested
-	if options.debug || options.trace {
+	if options.Debug || options.Trace {
 		printQuoteInfo(quote)
 		fmt.Fprintf(os.Stderr, "Quote Type: %s\n", quoteType)
 		if isClosing {
@@ -419,28 +409,25 @@ func getStockQuote(symbol string) (Value, error) {
 }
 
 // quoteToValue converts a QuoteResponse to a Value
-func quoteToValue(quote *QuoteResponse) Value {
+func quoteToValue(quote *QuoteResponse) rpn.Value {
 	// Create a Value with the price
-	priceNumber := newNumber(quote.Close)
+	priceNumber := new(rpn.Number).Set(quote.Close)
 
 	// Get the currency unit
 	currencyCode := strings.ToLower(quote.Currency)
-	var units Unit
+	var units rpn.Unit
 
 	// Try to find the currency unit in the UNITS map
-	if currencyUnit, ok := UNITS[currencyCode]; ok {
+	if currencyUnit, ok := rpn.UNITS[currencyCode]; ok {
 		units = currencyUnit
 	} else {
 		// Default to USD if currency not found
-		if usdUnit, ok := UNITS["usd"]; ok {
+		if usdUnit, ok := rpn.UNITS["usd"]; ok {
 			units = usdUnit
 		}
 	}
 
-	return Value{
-		number: priceNumber,
-		units:  units,
-	}
+	return rpn.NewValue(priceNumber, units)
 }
 
 // printQuoteInfo displays detailed quote information
@@ -458,7 +445,7 @@ func printQuoteInfo(quote *QuoteResponse) {
 		change, _ := strconv.ParseFloat(quote.Change, 64)
 		changeStr := fmt.Sprintf("%+.2f", change)
 		if change < 0 {
-			changeStr = red(changeStr)
+			changeStr = rpn.Red(changeStr)
 		} else if change > 0 {
 			changeStr = green(changeStr)
 		}
@@ -468,7 +455,7 @@ func printQuoteInfo(quote *QuoteResponse) {
 			pctChange, _ := strconv.ParseFloat(quote.PercentChange, 64)
 			pctStr := fmt.Sprintf("%+.2f%%", pctChange)
 			if pctChange < 0 {
-				pctStr = red(pctStr)
+				pctStr = rpn.Red(pctStr)
 			} else if pctChange > 0 {
 				pctStr = green(pctStr)
 			}
@@ -591,8 +578,8 @@ func printDetailedQuoteSummary() {
 			pctText := fmt.Sprintf("%+.2f%%", pctChange)
 
 			if change < 0 {
-				changeAmtStr = red(amtText)
-				changePctStr = red(pctText)
+				changeAmtStr = rpn.Red(amtText)
+				changePctStr = rpn.Red(pctText)
 			} else if change > 0 {
 				changeAmtStr = green(amtText)
 				changePctStr = green(pctText)
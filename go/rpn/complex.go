@@ -0,0 +1,251 @@
+// Copyright 2024 Mike Carlton
+// Released under terms of the MIT License:
+//   http://www.opensource.org/licenses/mit-license.php
+
+package rpn
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+// Complex numbers have exact rational real and imaginary parts; arithmetic on them is exact,
+// while roots, logs and non-integer powers are computed to the working precision
+
+func (n *Number) isComplex() bool {
+	return n.imag != nil && n.imag.Sign() != 0
+}
+
+// imagPart returns the imaginary part, zero for real numbers
+func (n *Number) imagPart() *big.Rat {
+	if n.imag == nil {
+		return new(big.Rat)
+	}
+	return n.imag
+}
+
+// setImag sets the imaginary part, a zero imaginary part leaves a real number
+func (n *Number) setImag(imag *big.Rat) *Number {
+	if imag != nil && imag.Sign() == 0 {
+		imag = nil
+	}
+	n.imag = imag
+	return n
+}
+
+func (n *Number) isZero() bool {
+	return n.Rat.Sign() == 0 && !n.isComplex()
+}
+
+func newComplex(re, imag *big.Rat) *Number {
+	return (&Number{Rat: re}).setImag(imag)
+}
+
+// formatRectangular formats a complex number as a+bi
+func (n *Number) formatRectangular(precisionLimit int) string {
+	imag := formatRat(new(big.Rat).Abs(n.imag), precisionLimit) + "i"
+	sign := "+"
+	if n.imag.Sign() < 0 {
+		sign = "-"
+	}
+
+	if n.Rat.Sign() == 0 {
+		return strings.TrimPrefix(sign, "+") + imag
+	}
+	return formatRat(n.Rat, precisionLimit) + sign + imag
+}
+
+// formatPolar formats a complex number as magnitude∠angle, with the angle in degrees
+func (n *Number) formatPolar(precisionLimit int) string {
+	p := workingPrecision(precisionLimit)
+	prec := p.bits(0)
+	angle := bigAtan2(n.imag, n.Rat, prec)
+	angle.Mul(angle, big.NewFloat(180))
+	angle.Quo(angle, bigPi(prec))
+
+	return fmt.Sprintf("%s∠%s°", magnitude(n, p).format(precisionLimit), p.fromFloat(angle).format(precisionLimit))
+}
+
+// formatWith formats n in the precision and complex form selected in opts
+func (n *Number) formatWith(opts *Options) string {
+	if opts.Polar && n.isComplex() {
+		return n.formatPolar(opts.Precision)
+	}
+	return n.format(opts.Precision)
+}
+
+// toRational formats n as numerator/denominator, with both parts for complex numbers
+func toRational(n *Number) string {
+	if !n.isComplex() {
+		return n.Rat.String()
+	}
+
+	sign := "+"
+	if n.imag.Sign() < 0 {
+		sign = ""
+	}
+	return fmt.Sprintf("%s%s%si", n.Rat.String(), sign, n.imag.String())
+}
+
+var complexNumber = `(?:\d[\d,_]*(?:\.[\d,_]*)?|\.\d[\d,_]*)(?:[eE][+-]?\d+)?`
+var rectangularPattern = regexp.MustCompile(fmt.Sprintf(`^([+-]?%s)([+-])(%s)?[ij]$`, complexNumber, complexNumber))
+var imaginaryPattern = regexp.MustCompile(fmt.Sprintf(`^([+-]?)(%s)?[ij]$`, complexNumber))
+
+// parseComplex parses a+bi, bi, i (or with j for i)
+func parseComplex(input string) (*Number, bool) {
+	parsePart := func(sign, digits string) *big.Rat {
+		if digits == "" {
+			digits = "1"
+		}
+		part, ok := new(Number).SetString(sign + digits)
+		if !ok {
+			return nil
+		}
+		return part.Rat
+	}
+
+	var re, imag *big.Rat
+	if match := rectangularPattern.FindStringSubmatch(input); match != nil {
+		re, imag = parsePart("", match[1]), parsePart(match[2], match[3])
+	} else if match := imaginaryPattern.FindStringSubmatch(input); match != nil {
+		re, imag = new(big.Rat), parsePart(match[1], match[2])
+	}
+
+	if re == nil || imag == nil {
+		return nil, false
+	}
+	return newComplex(re, imag), true
+}
+
+// complexIntPow returns base**exp for a non-negative integer exponent, by repeated squaring
+func complexIntPow(base *Number, exp int64) *Number {
+	result := newNumber(1)
+	for ; exp > 0; exp >>= 1 {
+		if exp&1 == 1 {
+			result = mul(result, base)
+		}
+		base = mul(base, base)
+	}
+	return result
+}
+
+// magnitude returns |x| = sqrt(re² + im²), exact when it is rational
+func magnitude(x *Number, p precision) *Number {
+	if !x.isComplex() {
+		return &Number{Rat: new(big.Rat).Abs(x.Rat)}
+	}
+
+	norm := new(big.Rat).Add(new(big.Rat).Mul(x.Rat, x.Rat), new(big.Rat).Mul(x.imag, x.imag))
+	result, _ := sqrt(&Number{Rat: norm}, nil, p)
+	return result
+}
+
+// complexSqrt returns the principal square root of a negative or complex x
+func complexSqrt(x *Number, p precision) *Number {
+	// sqrt(a + bi) = sqrt((|x| + a)/2) ± sqrt((|x| - a)/2)i, with the sign of b
+	r := magnitude(x, p)
+	two := newNumber(2)
+	re, _ := sqrt(div(add(r, &Number{Rat: x.Rat}), two), nil, p)
+	imag, _ := sqrt(div(sub(r, &Number{Rat: x.Rat}), two), nil, p)
+	if x.imagPart().Sign() < 0 {
+		imag.Rat.Neg(imag.Rat)
+	}
+
+	return newComplex(re.Rat, imag.Rat)
+}
+
+// complexLog returns the principal log of a negative or complex x, in the given base (0 for natural log)
+func complexLog(x *Number, base int64, p precision) *Number {
+	// ln(x) = ln|x| + arg(x)i
+	re := logBase(magnitude(x, p), base, p)
+	prec := p.bits(0)
+	angle := bigAtan2(x.imagPart(), x.Rat, prec)
+	if base != 0 {
+		angle.Quo(angle, bigLog(new(big.Float).SetPrec(prec).SetInt64(base), prec))
+	}
+
+	return newComplex(re.Rat, p.fromFloat(angle).Rat)
+}
+
+// complexPow returns the principal value of x**y where x is negative or complex, or y is complex
+func complexPow(x, y *Number, p precision) (*Number, error) {
+	if x.isZero() {
+		if y.Rat.Sign() > 0 {
+			return newNumber(0), nil
+		}
+		return nil, fmt.Errorf("%w: cannot raise zero to a power with a non-positive real part", ErrDomain)
+	}
+	if !y.isComplex() && y.Rat.Cmp(big.NewRat(1, 2)) == 0 {
+		return complexSqrt(x, p), nil
+	}
+
+	// x**y = e**(y ln(x)); with ln(x) = ln(r) + θi and y = c + di,
+	// y ln(x) = u + vi where u = c ln(r) - dθ and v = d ln(r) + cθ, so x**y = e**u (cos(v) + i sin(v))
+	norm := new(big.Rat).Add(new(big.Rat).Mul(x.Rat, x.Rat), new(big.Rat).Mul(x.imagPart(), x.imagPart()))
+	normFloat, _ := norm.Float64()
+	reFloat, _ := x.Rat.Float64()
+	imagFloat, _ := x.imagPart().Float64()
+	c, _ := y.Rat.Float64()
+	d, _ := y.imagPart().Float64()
+	exponent := (c*math.Log(normFloat)/2 - d*math.Atan2(imagFloat, reFloat)) / math.Ln10
+	if math.IsNaN(exponent) || math.Abs(exponent) > maxDecimalExponent {
+		return nil, fmt.Errorf("%w: result of power is out of range", ErrDomain)
+	}
+
+	prec := p.bits(max(0, int(exponent)) + guardDigits)
+	lnr := bigLog(new(big.Float).SetPrec(prec).SetRat(norm), prec)
+	lnr.Quo(lnr, big.NewFloat(2))
+	theta := bigAtan2(x.imagPart(), x.Rat, prec)
+	cFloat := new(big.Float).SetPrec(prec).SetRat(y.Rat)
+	dFloat := new(big.Float).SetPrec(prec).SetRat(y.imagPart())
+
+	u := new(big.Float).SetPrec(prec).Mul(cFloat, lnr)
+	u.Sub(u, new(big.Float).SetPrec(prec).Mul(dFloat, theta))
+	v := new(big.Float).SetPrec(prec).Mul(dFloat, lnr)
+	v.Add(v, new(big.Float).SetPrec(prec).Mul(cFloat, theta))
+
+	scale := bigExp(u, prec)
+	sin, cos := bigSinCos(v, prec+smallBits(v))
+	re := cos.Mul(cos, scale)
+	imag := sin.Mul(sin, scale)
+
+	// Parts that are only rounding error relative to the magnitude (e.g. the real part of (-1)**0.5) are zero
+	negligible := scale.MantExp(nil) - int(p.bits(0))
+	for _, part := range []*big.Float{re, imag} {
+		if part.Sign() != 0 && part.MantExp(nil) < negligible {
+			part.SetInt64(0)
+		}
+	}
+
+	return newComplex(p.fromFloat(re).Rat, p.fromFloat(imag).Rat), nil
+}
+
+// Complex operations; all but arg keep the units of their argument
+func absolute(x, y *Number, p precision) (*Number, error) {
+	return magnitude(x, p), nil
+}
+
+func argument(x, y *Number, p precision) (*Number, error) {
+	if x.isZero() {
+		return nil, fmt.Errorf("%w: arg is undefined for zero", ErrDomain)
+	}
+	if result, ok := exactAtan2(x.imagPart(), x.Rat); ok {
+		return result, nil
+	}
+	return p.fromFloat(bigAtan2(x.imagPart(), x.Rat, p.bits(0))), nil
+}
+
+func conjugate(x, y *Number, p precision) (*Number, error) {
+	return newComplex(new(big.Rat).Set(x.Rat), new(big.Rat).Neg(x.imagPart())), nil
+}
+
+func realPart(x, y *Number, p precision) (*Number, error) {
+	return &Number{Rat: new(big.Rat).Set(x.Rat)}, nil
+}
+
+func imaginaryPart(x, y *Number, p precision) (*Number, error) {
+	return &Number{Rat: new(big.Rat).Set(x.imagPart())}, nil
+}
@@ -0,0 +1,214 @@
+// Copyright 2024 Mike Carlton
+// Released under terms of the MIT License:
+//   http://www.opensource.org/licenses/mit-license.php
+
+package rpn
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Composite units show a value as a whole number of each of several units of one family and the rest
+// in the last: 1.8 m ft+in is 5 ft 10.8661 in, 1e6 s d+hr+min+s is 11 day 13 hr 46 min 40 s.
+// They are units like any other, the size of the smallest unit, so values in them convert and combine as usual,
+// and are named for the smallest unit wherever they are not the only unit of a value (5ft11in 2 s / is 35.5 in/s).
+// A number given in a composite unit counts the largest unit: 1.5 ft+in is 1 ft 6 in.
+// A number of each of the units, written together, is a value in the composite unit: 5ft11in, 5'11", 2lb3oz
+
+// UNIT_FAMILIES are the units that make up composite units, largest first
+var UNIT_FAMILIES = [][]string{
+	{"mi", "yd", "ft", "in"},
+	{"km", "m", "cm", "mm"},
+	{"st", "lb", "oz"},
+	{"kg", "g"},
+	{"yr", "wk", "day", "hr", "min", "s"},
+	{"gal", "qt", "pt", "cup", "foz"},
+	{"ukgal", "ukpt"},
+	{"deg", "arcmin", "arcsec"},
+}
+
+// COMPOSITE_ALIASES are short names for units in composite units and values (d+h+min, 5'11")
+var COMPOSITE_ALIASES = Aliases{
+	"d": "day",
+	"h": "hr",
+	"'": "ft",
+	`"`: "in",
+	"′": "ft",
+	"″": "in",
+}
+
+const compositeSeparator = "+"
+
+// family returns the family of unit name and its position there
+func family(name string) (int, int, bool) {
+	for i, units := range UNIT_FAMILIES {
+		if position := slices.Index(units, name); position >= 0 {
+			return i, position, true
+		}
+	}
+	return 0, 0, false
+}
+
+// compositeUnits returns the units of names, which must be two or more units of one family, largest first
+func compositeUnits(names []string) ([]UnitPower, bool) {
+	if len(names) < 2 {
+		return nil, false
+	}
+
+	var units []UnitPower
+	first, last := -1, -1
+	for _, name := range names {
+		name = unalias(COMPOSITE_ALIASES, name)
+		i, position, ok := family(name)
+		if !ok || first >= 0 && (i != first || position <= last) {
+			return nil, false
+		}
+		first, last = i, position
+
+		for _, unit := range UNITS[name] {
+			if unit.power != 0 {
+				units = append(units, unit)
+			}
+		}
+	}
+	return units, len(units) == len(names)
+}
+
+// parseComposite parses a composite unit, e.g. ft+in, as a unit of the size of the last one
+func parseComposite(input string) (Unit, bool) {
+	units, ok := compositeUnits(strings.Split(input, compositeSeparator))
+	if !ok {
+		return nil, false
+	}
+
+	var names, descriptions []string
+	for _, unit := range units {
+		names = append(names, unit.name)
+		descriptions = append(descriptions, unit.description)
+	}
+
+	smallest := units[len(units)-1]
+	smallest.name = strings.Join(names, compositeSeparator)
+	smallest.description = strings.Join(descriptions, " and ")
+	return Unit{smallest.dimension: smallest}, true
+}
+
+// smallestUnits returns v with each composite unit replaced by its smallest unit, which it counts in (ft+in by in)
+func (v Unit) smallestUnits() Unit {
+	var reduced Unit
+	for dim, unit := range v {
+		names := strings.Split(unit.name, compositeSeparator)
+		if len(names) < 2 {
+			continue
+		}
+		if reduced == nil {
+			reduced = v.clone()
+		}
+		reduced[dim] = UnitPower{UNITS[names[len(names)-1]][dim].BaseUnit, unit.power}
+	}
+	if reduced == nil {
+		return v
+	}
+	return reduced
+}
+
+// largestSize returns the size of the largest unit of v, when v is only a composite unit, in its smallest unit
+// A number given in a composite unit counts its largest unit, so 1.5 ft+in is 1 ft 6 in
+func (v Unit) largestSize() (*Number, bool) {
+	dims := v.dimensions()
+	if len(dims) != 1 || v[dims[0]].power != 1 {
+		return nil, false
+	}
+	units, ok := compositeUnits(strings.Split(v[dims[0]].name, compositeSeparator))
+	if !ok {
+		return nil, false
+	}
+	return div(units[0].scale(), units[len(units)-1].scale()), true
+}
+
+// compositePattern matches a number and the units after it
+var compositePattern = regexp.MustCompile(`^([°a-zA-Z'"′″]+)`)
+
+// parseCompositeValue parses a number of each of two or more units of one family, largest first,
+// e.g. 5ft11in or 5'11"; a sign on the first applies to the whole value
+func parseCompositeValue(input string) (*Number, Unit, bool) {
+	var numbers []*Number
+	var names []string
+	for rest := input; rest != ""; {
+		number, remainder := NewFromString(rest)
+		if number == nil || len(numbers) > 0 && strings.ContainsAny(rest[:1], "+-") {
+			return nil, nil, false
+		}
+		name := compositePattern.FindString(remainder)
+		if name == "" {
+			return nil, nil, false
+		}
+		numbers, names = append(numbers, number), append(names, name)
+		rest = remainder[len(name):]
+	}
+
+	units, ok := compositeUnits(names)
+	if !ok {
+		return nil, nil, false
+	}
+	composite, _ := parseComposite(strings.Join(names, compositeSeparator))
+
+	// The sign of the first number applies to all, even when that number is zero: -0ft6in
+	negative := strings.HasPrefix(input, "-")
+	smallest := units[len(units)-1]
+	total := newNumber(0)
+	for i, number := range numbers {
+		part := mul(number, div(units[i].scale(), smallest.scale()))
+		if i > 0 && negative {
+			part = mul(part, newNumber(-1))
+		}
+		total = add(total, part)
+	}
+	return total, composite, true
+}
+
+// formatComposite formats v in a composite unit as a whole number of each unit but the last,
+// which has the rest rounded to the precision; units of which there are none are left out
+func (v Value) formatComposite(opts *Options) (string, bool) {
+	dims := v.units.dimensions()
+	if len(dims) != 1 || v.units[dims[0]].count() != 1 || v.number.isComplex() {
+		return "", false
+	}
+	names := strings.Split(v.units[dims[0]].name, compositeSeparator)
+	units, ok := compositeUnits(names)
+	if !ok {
+		return "", false
+	}
+
+	// Round first, so the last unit never shows a whole one of the unit before it (5 ft 12 in)
+	rest, _ := new(big.Rat).SetString(new(big.Rat).Abs(v.number.Rat).FloatString(opts.Precision))
+
+	var parts []string
+	smallest := units[len(units)-1]
+	for i, unit := range units {
+		if i == len(units)-1 {
+			if rest.Sign() != 0 || len(parts) == 0 {
+				parts = append(parts, fmt.Sprintf("%s %s", formatRat(rest, opts.Precision), unit.name))
+			}
+			break
+		}
+
+		size := div(unit.scale(), smallest.scale()).Rat
+		quotient := new(big.Rat).Quo(rest, size)
+		count := new(big.Int).Quo(quotient.Num(), quotient.Denom())
+		if count.Sign() != 0 {
+			parts = append(parts, fmt.Sprintf("%s %s", count, unit.name))
+			rest.Sub(rest, new(big.Rat).Mul(new(big.Rat).SetInt(count), size))
+		}
+	}
+
+	result := strings.Join(parts, " ")
+	if v.number.Rat.Sign() < 0 {
+		result = "-" + result
+	}
+	return result, true
+}
@@ -0,0 +1,330 @@
+// Copyright 2024 Mike Carlton
+// Released under terms of the MIT License:
+//   http://www.opensource.org/licenses/mit-license.php
+
+package rpn
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Unit definitions files use a syntax like GNU units: one "name = definition" per line,
+// with '#' comments, e.g.
+//
+//	furlong = 660 ft
+//	U = 1.75 in        # rack units
+//	sprint* = 2 wk     # '*' accepts SI prefixes: ksprint
+//	request* !         # '!' makes a new base dimension: $/request, krequest/s
+//
+// Definitions are RPN expressions (see Evaluator.DefineUnit) and may use units defined anywhere in the file
+
+// unitDefinition is one line of a definitions file
+type unitDefinition struct {
+	name       string
+	definition string
+	prefixes   bool // add every SI prefix, as for the SI units
+	line       int
+}
+
+// primitive is the definition of a unit that is the base unit of a new dimension
+const primitive = "!"
+
+// referencePattern finds the unit names used in a definition
+var referencePattern = regexp.MustCompile(`[°a-zA-Z$€£¥Ωμ]+`)
+
+// LoadUnits adds the units defined in r to those of e, reporting errors as source:line
+// Units are defined after the units they use, so the order in the file does not matter;
+// cycles and names that are already units, operators or constants are errors,
+// and on an error none of the units or dimensions of the file are added
+func (e *Evaluator) LoadUnits(r io.Reader, source string) (err error) {
+	entries, err := parseUnitDefinitions(r, source)
+	if err != nil {
+		return err
+	}
+
+	saved := e.defs.clone()
+	defer func() {
+		if err != nil {
+			*e.defs = *saved
+		}
+	}()
+
+	byName := make(map[string]*unitDefinition, len(entries))
+	for i, d := range entries {
+		if previous, ok := byName[d.name]; ok {
+			return fmt.Errorf("%s:%d: %w: unit '%s' already defined at line %d", source, d.line, ErrDefinition, d.name, previous.line)
+		}
+		byName[d.name] = &entries[i]
+	}
+
+	const (
+		visiting = 1
+		defined  = 2
+	)
+	state := map[string]int{}
+	var path []string
+
+	var define func(d *unitDefinition) error
+	define = func(d *unitDefinition) error {
+		switch state[d.name] {
+		case defined:
+			return nil
+		case visiting:
+			cycle := append(path[slices.Index(path, d.name):], d.name)
+			return fmt.Errorf("%s:%d: %w: cycle in unit definitions: %s", source, d.line, ErrDefinition, strings.Join(cycle, " -> "))
+		}
+
+		state[d.name] = visiting
+		path = append(path, d.name)
+		for _, reference := range d.references(byName) {
+			if err := define(reference); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+
+		if err := e.defineUnit(d.name, d.definition, d.prefixes); err != nil {
+			return fmt.Errorf("%s:%d: %w", source, d.line, err)
+		}
+		state[d.name] = defined
+		return nil
+	}
+
+	for i := range entries {
+		if err := define(&entries[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseUnitDefinitions reads the name = definition lines of r
+func parseUnitDefinitions(r io.Reader, source string) ([]unitDefinition, error) {
+	var definitions []unitDefinition
+	lineNumber := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lineNumber++
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		name, definition, ok := strings.Cut(line, "=")
+		if before, found := strings.CutSuffix(line, primitive); !ok && found {
+			name, definition, ok = before, primitive, true
+		}
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: %w: expected name = definition, found '%s'", source, lineNumber, ErrDefinition, line)
+		}
+		name, definition = strings.TrimSpace(name), strings.TrimSpace(definition)
+		name, prefixes := strings.CutSuffix(name, "*")
+		if definition == "" {
+			return nil, fmt.Errorf("%s:%d: %w: missing definition for '%s'", source, lineNumber, ErrDefinition, name)
+		}
+
+		definitions = append(definitions, unitDefinition{name: name, definition: definition, prefixes: prefixes, line: lineNumber})
+	}
+
+	return definitions, scanner.Err()
+}
+
+// references returns the definitions in byName that d uses, directly or with an SI prefix
+func (d *unitDefinition) references(byName map[string]*unitDefinition) []*unitDefinition {
+	var references []*unitDefinition
+	for _, word := range referencePattern.FindAllString(d.definition, -1) {
+		if reference, ok := byName[word]; ok {
+			references = append(references, reference)
+			continue
+		}
+		for _, prefix := range SI_PREFIXES {
+			if reference, ok := byName[strings.TrimPrefix(word, prefix.symbol)]; ok && reference.prefixes && strings.HasPrefix(word, prefix.symbol) {
+				references = append(references, reference)
+				break
+			}
+		}
+	}
+	return references
+}
+
+// defineUnit adds unit name, or a new dimension with base unit name for the primitive definition, and, if prefixes is set, the unit with every SI prefix that is not already a word
+func (e *Evaluator) defineUnit(name, definition string, prefixes bool) error {
+	var err error
+	if definition == primitive {
+		err = e.DefineBaseUnit(name, name)
+	} else {
+		err = e.DefineUnit(name, name, definition)
+	}
+	if err != nil {
+		return err
+	}
+
+	if prefixes {
+		d := e.defs
+		for _, prefix := range SI_PREFIXES {
+			symbol := prefix.symbol + name
+			if _, ok := d.reservedWord(symbol); ok {
+				continue
+			}
+			if _, exists := d.unit(symbol); exists {
+				continue
+			}
+			if unit, ok := prefixedUnit(name, d.units[name], prefix.symbol, prefix.name, intPow(newNumber(10), prefix.power)); ok {
+				d.units[symbol] = unit
+			}
+		}
+		d.prefixed = append(d.prefixed, name)
+	}
+	return nil
+}
+
+// definitions are the units, dimensions, constants, aliases and preferred units that an Evaluator adds
+// to the built-in ones, so evaluators do not see each other's; a nil *definitions has only the built-in ones
+type definitions struct {
+	units        map[string]Unit   // user-defined units, including their prefixed forms
+	prefixed     []string          // user-defined units that take SI prefixes, after UNITS_FOR_PREFIXES
+	dimensions   []string          // names of the user-defined dimensions, numbered from NumDimension
+	constants    map[string]Value  // user-defined constants, which may replace built-in ones
+	opAliases    Aliases           // user-defined names for operators, before OPALIAS
+	stackAliases Aliases           // user-defined names for stack operations, before STACKALIAS
+	preferred    map[string]string // the unit to display values in, by their dimensions (see Unit.signature)
+	currency     *CurrencyConverter
+}
+
+func newDefinitions(currency *CurrencyConverter) *definitions {
+	return &definitions{
+		units:        map[string]Unit{},
+		constants:    map[string]Value{},
+		opAliases:    Aliases{},
+		stackAliases: Aliases{},
+		preferred:    map[string]string{},
+		currency:     currency,
+	}
+}
+
+// clone returns a copy of d that can be changed without changing d
+func (d *definitions) clone() *definitions {
+	return &definitions{
+		units:        maps.Clone(d.units),
+		prefixed:     slices.Clone(d.prefixed),
+		dimensions:   slices.Clone(d.dimensions),
+		constants:    maps.Clone(d.constants),
+		opAliases:    maps.Clone(d.opAliases),
+		stackAliases: maps.Clone(d.stackAliases),
+		preferred:    maps.Clone(d.preferred),
+		currency:     d.currency,
+	}
+}
+
+// unit returns unit name, user-defined or built-in; currencies convert with the exchange rates of the evaluator
+func (d *definitions) unit(name string) (Unit, bool) {
+	if d != nil {
+		if unit, ok := d.units[name]; ok {
+			return unit, true
+		}
+	}
+	unit, ok := UNITS[name]
+	return d.withRates(unit), ok
+}
+
+// withRates returns units with any currency converted by the exchange rates of d
+func (d *definitions) withRates(units Unit) Unit {
+	part := units[Currency]
+	if d == nil || part.factorFunction == nil {
+		return units
+	}
+	units = units.clone()
+	part.factorFunction = func(amount *Number, from, to BaseUnit) (*Number, error) {
+		return convertCurrency(*d.currency, amount, from, to)
+	}
+	units[Currency] = part
+	return units
+}
+
+// constant returns constant name, user-defined or built-in
+func (d *definitions) constant(name string) (Value, bool) {
+	if d != nil {
+		if constant, ok := d.constants[name]; ok {
+			return constant, true
+		}
+	}
+	constant, ok := CONSTANTS[name]
+	return constant, ok
+}
+
+// operator returns the operator that name is an alias for, or name
+func (d *definitions) operator(name string) string {
+	if d != nil {
+		if op, ok := d.opAliases[name]; ok {
+			return op
+		}
+	}
+	return unalias(OPALIAS, name)
+}
+
+// stackOperation returns the stack operation that name is an alias for, or name
+func (d *definitions) stackOperation(name string) string {
+	if d != nil {
+		if op, ok := d.stackAliases[name]; ok {
+			return op
+		}
+	}
+	return unalias(STACKALIAS, name)
+}
+
+// preferredUnit returns the unit d displays values in the units v in, if it has one
+func (d *definitions) preferredUnit(v Unit) (string, bool) {
+	if d == nil {
+		return "", false
+	}
+	name, ok := d.preferred[v.signature()]
+	return name, ok
+}
+
+// prefixedUnits returns the units that take SI prefixes, built-in first
+func (d *definitions) prefixedUnits() []string {
+	if d == nil {
+		return UNITS_FOR_PREFIXES
+	}
+	return slices.Concat(UNITS_FOR_PREFIXES, d.prefixed)
+}
+
+// registerDimension adds a base dimension (e.g. "requests"), checked and converted like the built-in ones
+func (d *definitions) registerDimension(name string) (Dimension, error) {
+	if name == "" || slices.Contains(dimensionNames, name) || slices.Contains(d.dimensions, name) {
+		return 0, fmt.Errorf("%w: dimension '%s' already exists", ErrDefinition, name)
+	}
+
+	d.dimensions = append(d.dimensions, name)
+	return NumDimension + Dimension(len(d.dimensions)-1), nil
+}
+
+// dimensionName returns the name of dim, built-in or user-defined
+func (d *definitions) dimensionName(dim Dimension) string {
+	if d != nil && dim >= NumDimension && int(dim-NumDimension) < len(d.dimensions) {
+		return d.dimensions[dim-NumDimension]
+	}
+	return dim.String()
+}
+
+// reservedWord describes the operator, stack operation or constant that name already is,
+// which a unit of the same name would hide or be hidden by
+func (d *definitions) reservedWord(name string) (string, bool) {
+	if _, ok := OPERATOR[d.operator(name)]; ok {
+		return fmt.Sprintf("operator '%s'", name), true
+	}
+	if _, ok := STACKOP[d.stackOperation(name)]; ok {
+		return fmt.Sprintf("stack operation '%s'", name), true
+	}
+	if _, ok := d.constant(name); ok {
+		return fmt.Sprintf("constant '%s'", name), true
+	}
+	return "", false
+}
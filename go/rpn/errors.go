@@ -0,0 +1,45 @@
+// Copyright 2024 Mike Carlton
+// Released under terms of the MIT License:
+//   http://www.opensource.org/licenses/mit-license.php
+
+package rpn
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Sentinel errors, wrapped with details by the operations that fail; test with errors.Is
+var (
+	ErrStackUnderflow    = errors.New("not enough arguments")
+	ErrIncompatibleUnits = errors.New("incompatible units")
+	ErrDimensionless     = errors.New("dimensionless value required")
+	ErrNotInteger        = errors.New("integer value required")
+	ErrDomain            = errors.New("domain error")
+	ErrDivisionByZero    = errors.New("division by zero")
+	ErrConversion        = errors.New("conversion failed")
+	ErrUnknownToken      = errors.New("unrecognized argument")
+	ErrUnknownRegister   = errors.New("non-existent register")
+	ErrDefinition        = errors.New("invalid definition")
+	ErrSyntax            = errors.New("syntax error")
+)
+
+// Error records the token that failed and its position in the input
+type Error struct {
+	Token    string
+	Position int // 1-based index of the token among all input tokens
+	Err      error
+}
+
+func (e *Error) Error() string {
+	// Most errors already name the token ('foo'), so only the position is added
+	if strings.Contains(e.Err.Error(), "'"+e.Token+"'") {
+		return fmt.Sprintf("%v (token %d)", e.Err, e.Position)
+	}
+	return fmt.Sprintf("%v (at '%s', token %d)", e.Err, e.Token, e.Position)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
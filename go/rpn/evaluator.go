@@ -0,0 +1,406 @@
+// Copyright 2024 Mike Carlton
+// Released under terms of the MIT License:
+//   http://www.opensource.org/licenses/mit-license.php
+
+// Package rpn is the RPN engine of calc: exact rational numbers with units, a value stack,
+// and an Evaluator that applies tokens to the stack
+package rpn
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Options control how an Evaluator parses and displays values
+type Options struct {
+	Precision    int  // display precision for floating point numbers
+	Superscript  bool // use superscript powers for units (otherwise ^)
+	Base         bool // display units as base units only (no derived units)
+	Group        bool // group digits: ',' for decimal, '_' for other bases
+	ShowBinary   bool
+	ShowHex      bool
+	ShowHexFloat bool
+	ShowOctal    bool
+	ShowIPv4     bool
+	ShowIEEE32   bool
+	ShowIEEE64   bool
+	ShowRational bool
+	ShowFactor   bool
+	Polar        bool // display complex numbers as magnitude∠angle
+	Infix        bool // parse each argument as an infix expression (those with parentheses outside of units always are)
+	AutoPrefix   bool // display values in SI units with the prefix that puts the mantissa in [1, 1000)
+	Trace        bool // show the stack before each token
+	Debug        bool // show each unit conversion
+
+	defs *definitions // units and preferred units to display with, set by the Stack displaying values
+}
+
+const defaultPrecision = 4
+
+// DefaultOptions returns the options used by the calc command when no flags are given
+func DefaultOptions() Options {
+	return Options{
+		Precision:   defaultPrecision,
+		Superscript: true, // Default to using superscript
+	}
+}
+
+type Aliases map[string]string
+
+func unalias(aliases Aliases, input string) string {
+	if name, ok := aliases[input]; ok {
+		return name
+	}
+	return input
+}
+
+// CONSTANTS are the built-in constants; Evaluator.DefineConstant adds to those of one evaluator
+var CONSTANTS = map[string]Value{
+	"e": { // e = 2.718281828459045235
+		number: newRationalNumber(2_718_281_828_459_045_235, 1_000_000_000_000_000_000),
+	},
+	"pi": {
+		number: Pi,
+	},
+	"G": { // g = 9.80665 m/s²
+		number: newRationalNumber(980_665, 100_000),
+		units: Unit{Length: UnitPower{BaseUnit{name: "m", dimension: Length, factor: newNumber(1)}, 1},
+			Time: UnitPower{BaseUnit{name: "s", dimension: Time, factor: newNumber(1)}, -2}},
+	},
+	"c": { // c = 299,792,458 m/s
+		number: newNumber(299_792_458),
+		units: Unit{Length: UnitPower{BaseUnit{name: "m", dimension: Length, factor: newNumber(1)}, 1},
+			Time: UnitPower{BaseUnit{name: "s", dimension: Time, factor: newNumber(1)}, -1}},
+	},
+	"NA": { // NA = 6.02214076e23 /mol
+		number: mul(newNumber(602_214_076), intPow(newNumber(10), 15)),
+		units:  Unit{Amount: UnitPower{BaseUnit{name: "mol", dimension: Amount, factor: newNumber(1)}, -1}},
+	},
+	"R": { // R = NA·k = 8.31446261815324 J/K·mol
+		number: newRationalNumber(831_446_261_815_324, 100_000_000_000_000),
+		units: Unit{Mass: UnitPower{BaseUnit{name: "kg", dimension: Mass, factor: newNumber(1_000)}, 1},
+			Length:      UnitPower{BaseUnit{name: "m", dimension: Length, factor: newNumber(1)}, 2},
+			Time:        UnitPower{BaseUnit{name: "s", dimension: Time, factor: newNumber(1)}, -2},
+			Temperature: UnitPower{BaseUnit{name: "K", dimension: Temperature, factor: newNumber(1)}, -1},
+			Amount:      UnitPower{BaseUnit{name: "mol", dimension: Amount, factor: newNumber(1)}, -1}},
+	},
+}
+
+// DefineConstant adds constant name to e, with the value of the RPN expression definition (e.g. "6.674e-11 N·m²/kg²")
+func (e *Evaluator) DefineConstant(name, definition string) error {
+	if _, ok := parseNumber(name); ok || strings.ContainsAny(name, " \t") {
+		return fmt.Errorf("%w: cannot define constant '%s'", ErrDefinition, name)
+	}
+
+	value, err := e.evalDefinition(definition)
+	if err != nil {
+		return fmt.Errorf("constant '%s': %w", name, err)
+	}
+
+	e.defs.constants[name] = value
+	return nil
+}
+
+// AliasOperator makes alias another name for operator op in e (e.g. "^" for "**")
+func (e *Evaluator) AliasOperator(alias, op string) error {
+	if _, ok := OPERATOR[op]; !ok {
+		return fmt.Errorf("%w: unknown operator '%s' for alias '%s'", ErrDefinition, op, alias)
+	}
+	e.defs.opAliases[alias] = op
+	return nil
+}
+
+// AliasStackOperation makes alias another name for stack operation op in e (e.g. "swap" for "x")
+func (e *Evaluator) AliasStackOperation(alias, op string) error {
+	if _, ok := STACKOP[op]; !ok {
+		return fmt.Errorf("%w: unknown stack operation '%s' for alias '%s'", ErrDefinition, op, alias)
+	}
+	e.defs.stackAliases[alias] = op
+	return nil
+}
+
+// evalDefinition evaluates definition on a new stack with the definitions of e, which must leave exactly one value
+func (e *Evaluator) evalDefinition(definition string) (Value, error) {
+	definer := &Evaluator{Options: DefaultOptions(), Output: e.Output, stack: newStack(e.defs), macros: map[string][]string{}, defs: e.defs}
+	stack, err := definer.Eval([]string{definition})
+	if err != nil {
+		return Value{}, err
+	}
+	if stack.size() != 1 {
+		return Value{}, fmt.Errorf("%w: '%s' must produce a single value", ErrDefinition, definition)
+	}
+
+	return stack.peek()
+}
+
+var tickerPattern = regexp.MustCompile(`^@([a-zA-Z]+)$`)
+
+// >NAME stores, <NAME recalls and >:NAME clears a register
+var registerPattern = regexp.MustCompile(`^(>:|>|<)([a-zA-Z][a-zA-Z0-9_]*)$`)
+
+// IsTickerSymbol checks if the input string is a ticker symbol (e.g., @aapl)
+func IsTickerSymbol(input string) (string, bool) {
+	matches := tickerPattern.FindStringSubmatch(input)
+	if len(matches) == 2 {
+		return strings.ToUpper(matches[1]), true
+	}
+	return "", false
+}
+
+// Evaluator applies tokens to its own stack, keeping the stack between calls to Eval
+// Units, constants, aliases and preferred units defined on an Evaluator are its own
+type Evaluator struct {
+	Options  Options
+	Output   io.Writer                          // destination for trace and debug output
+	Quote    func(symbol string) (Value, error) // looks up @ticker tokens, nil if unsupported
+	Currency CurrencyConverter                  // converts between currencies, nil if unsupported
+
+	stack  *Stack
+	macros map[string][]string // user-defined words, bodies already expanded
+	defs   *definitions        // user-defined units, constants, aliases and preferred units
+}
+
+// NewEvaluator returns an Evaluator with an empty stack and only the built-in units, constants and aliases
+func NewEvaluator(options Options) *Evaluator {
+	e := &Evaluator{Options: options, Output: os.Stdout, macros: map[string][]string{}}
+	e.defs = newDefinitions(&e.Currency)
+	e.stack = newStack(e.defs)
+	return e
+}
+
+// Stack returns the evaluator's stack
+func (e *Evaluator) Stack() *Stack {
+	return e.stack
+}
+
+// Eval applies each whitespace-separated token in tokens to the stack and returns it
+// Infix arguments (see Options.Infix) are compiled to RPN tokens first
+// Forth-style definitions (: name tokens ;) add macros instead of being applied;
+// a definition must be complete within one call
+// Errors are returned as *Error, recording the failing token and its position;
+// on error the stack, registers and macros are restored to their state before the call
+func (e *Evaluator) Eval(tokens []string) (*Stack, error) {
+	saved := append([]Value(nil), e.stack.values...)
+	savedRegisters := e.stack.Registers()
+	savedMacros := e.Macros()
+
+	e.stack.precision = workingPrecision(e.Options.Precision)
+
+	var fields []string
+	for _, arg := range tokens {
+		if e.Options.Infix || e.defs.isInfix(arg) {
+			compiled, err := e.defs.compileInfix(arg)
+			if err != nil {
+				// Positions are within the expression; count the tokens of the arguments before it
+				var infixErr *Error
+				if errors.As(err, &infixErr) {
+					infixErr.Position += len(fields)
+				}
+				return e.stack, err
+			}
+			fields = append(fields, compiled...)
+		} else {
+			fields = append(fields, strings.Fields(arg)...)
+		}
+	}
+
+	for i := 0; i < len(fields); i++ {
+		token := fields[i]
+		position := i + 1
+
+		var err error
+		if token == ":" {
+			i, err = e.define(fields, i)
+		} else {
+			if e.Options.Trace {
+				fmt.Fprintf(e.Output, "[%s] %s\n", e.stack.Oneline(&e.Options), token)
+			}
+			err = e.evalToken(token)
+		}
+
+		if err != nil {
+			e.stack.values = saved
+			e.stack.registers = savedRegisters
+			e.macros = savedMacros
+			return e.stack, &Error{Token: token, Position: position, Err: err}
+		}
+	}
+
+	return e.stack, nil
+}
+
+// Macros returns a copy of the user-defined macros, with their expanded bodies
+func (e *Evaluator) Macros() map[string][]string {
+	macros := make(map[string][]string, len(e.macros))
+	for name, body := range e.macros {
+		macros[name] = body
+	}
+	return macros
+}
+
+// Define adds the macros in tokens, which may only be definitions (: name tokens ;), as in a macros file;
+// errors are returned as *Error, and on error no macro is added
+func (e *Evaluator) Define(tokens []string) error {
+	savedMacros := e.Macros()
+
+	var fields []string
+	for _, arg := range tokens {
+		fields = append(fields, strings.Fields(arg)...)
+	}
+
+	for i := 0; i < len(fields); i++ {
+		start := i
+		var err error
+		if fields[i] == ":" {
+			i, err = e.define(fields, i)
+		} else {
+			err = fmt.Errorf("%w: '%s' is outside a definition", ErrDefinition, fields[i])
+		}
+
+		if err != nil {
+			e.macros = savedMacros
+			return &Error{Token: fields[start], Position: start + 1, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// define adds the macro defined by fields[start:], which begins with ':',
+// and returns the index of the closing ';'
+// Macros used in the body are expanded now, so redefining a word does not change
+// earlier definitions that use it (and a definition cannot recurse)
+func (e *Evaluator) define(fields []string, start int) (int, error) {
+	if start+1 >= len(fields) {
+		return start, fmt.Errorf("%w: missing name after ':'", ErrDefinition)
+	}
+
+	name := fields[start+1]
+	if _, ok := parseNumber(name); ok || name == ":" || name == ";" {
+		return start, fmt.Errorf("%w: cannot define '%s'", ErrDefinition, name)
+	}
+	// Macros are looked up first, so one named for a unit, operator or constant would hide it
+	if e.defs.isUnits(name) {
+		return start, fmt.Errorf("%w: macro '%s' conflicts with unit '%s'", ErrDefinition, name, name)
+	}
+	if word, ok := e.defs.reservedWord(name); ok {
+		return start, fmt.Errorf("%w: macro '%s' conflicts with %s", ErrDefinition, name, word)
+	}
+
+	body := []string{}
+	for i := start + 2; i < len(fields); i++ {
+		switch token := fields[i]; token {
+		case ";":
+			e.macros[name] = body
+			return i, nil
+		case ":":
+			return start, fmt.Errorf("%w: nested ':' in '%s'", ErrDefinition, name)
+		default:
+			if macro, ok := e.macros[token]; ok {
+				body = append(body, macro...)
+			} else {
+				body = append(body, token)
+			}
+		}
+	}
+
+	return start, fmt.Errorf("%w: missing ';' after '%s'", ErrDefinition, name)
+}
+
+// evalToken applies a single token to the stack
+func (e *Evaluator) evalToken(token string) error {
+	stack := e.stack
+
+	if body, ok := e.macros[token]; ok {
+		for _, word := range body {
+			if err := e.evalToken(word); err != nil {
+				return fmt.Errorf("in '%s': %w", token, err)
+			}
+		}
+	} else if num, ok := parseNumber(token); ok {
+		// If number contains comma or underscore separators, the stack displays with grouping
+		if strings.ContainsAny(token, ",_") {
+			stack.grouped = true
+		}
+		stack.push(Value{number: num})
+	} else if base60, ok := parseBase60(token); ok {
+		// Base-60 input with ':' - just a regular number
+		stack.push(Value{number: base60})
+	} else if ipv4, ok := parseIPv4(token); ok {
+		// IPv4 address input - convert to integer
+		stack.push(Value{number: ipv4})
+	} else if complex, ok := parseComplex(token); ok {
+		// Complex number input: 3+4i, -2j, i
+		stack.push(Value{number: complex})
+	} else if num, units, ok := e.defs.parseNumberWithUnits(token); ok {
+		// Number with attached units: 5kg, 3.5ft, 4Mm
+		value, err := Value{number: num}.apply(units)
+		if err != nil {
+			return err
+		}
+		stack.push(value)
+	} else if constant, ok := e.defs.constant(token); ok {
+		stack.push(constant)
+	} else if units, ok := e.defs.parseUnits(token); ok {
+		opts := stack.options(&e.Options)
+		if e.Options.Debug {
+			if value, err := stack.peek(); err == nil {
+				fmt.Fprintf(e.Output, "(%s).apply(%s) -->", value.Format(opts), units.Format(opts))
+			}
+		}
+		if err := stack.apply(units); err != nil {
+			return err
+		}
+		if e.Options.Debug {
+			value, _ := stack.peek()
+			fmt.Fprintf(e.Output, " %s\n", value.Format(opts))
+		}
+	} else if stackOp, ok := STACKOP[e.defs.stackOperation(token)]; ok {
+		return stackOp(stack)
+	} else if match := registerPattern.FindStringSubmatch(token); match != nil {
+		switch match[1] {
+		case ">":
+			return stack.store(match[2])
+		case "<":
+			return stack.recall(match[2])
+		default:
+			return stack.clearRegister(match[2])
+		}
+	} else if ticker, ok := IsTickerSymbol(token); ok {
+		// Stock ticker symbol (@aapl, @wday, etc.)
+		if e.Quote == nil {
+			return fmt.Errorf("%w: no stock quotes available for '%s'", ErrUnknownToken, ticker)
+		}
+		value, err := e.Quote(ticker)
+		if err != nil {
+			return fmt.Errorf("failed to get quote for '%s': %w", ticker, err)
+		}
+		value.units = e.defs.withRates(value.units)
+		stack.push(value)
+	} else if strings.HasPrefix(token, "@") && len(token) > 1 {
+		// Stack reduction operation (@+, @*, etc.)
+		opName := e.defs.operator(token[1:])
+		if operator, ok := OPERATOR[opName]; ok && !operator.unary {
+			return stack.reduce(opName)
+		}
+		return fmt.Errorf("%w: invalid reduction operation '%s'", ErrUnknownToken, token)
+	} else if operator, ok := OPERATOR[e.defs.operator(token)]; ok {
+		if operator.unary {
+			return stack.unaryOp(e.defs.operator(token))
+		}
+		return stack.binaryOp(e.defs.operator(token))
+	} else {
+		return fmt.Errorf("%w '%s'", ErrUnknownToken, token)
+	}
+
+	return nil
+}
+
+// Red colors text red on ANSI terminals, for errors and warnings
+func Red(text string) string {
+	return fmt.Sprintf("\033[31m%s\033[0m", text)
+}
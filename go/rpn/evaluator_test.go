@@ -0,0 +1,1029 @@
+package rpn
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// evalTest is a line evaluated by a new evaluator and the stack it leaves, as shown by Oneline
+type evalTest struct {
+	line     string
+	expected string
+}
+
+// runEvalTests evaluates each line with a new evaluator with options and checks the stack
+func runEvalTests(t *testing.T, options Options, tests []evalTest) {
+	t.Helper()
+	for _, test := range tests {
+		t.Run(test.line, func(t *testing.T) {
+			evaluator := NewEvaluator(options)
+			stack, err := evaluator.Eval([]string{test.line})
+			if err != nil {
+				t.Fatalf("Eval(%q) error = %v", test.line, err)
+			}
+			if stack.Oneline(&evaluator.Options) != test.expected {
+				t.Errorf("Eval(%q) = %q, want %q", test.line, stack.Oneline(&evaluator.Options), test.expected)
+			}
+		})
+	}
+}
+
+// builtInDefinitions has only the built-in units, constants and aliases, as a new Evaluator does
+var builtInDefinitions *definitions
+
+// runEvaluatorTests evaluates each line on an empty stack of evaluator, with its definitions, and checks the stack
+func runEvaluatorTests(t *testing.T, evaluator *Evaluator, tests []evalTest) {
+	t.Helper()
+	for _, test := range tests {
+		t.Run(test.line, func(t *testing.T) {
+			evaluator.stack = newStack(evaluator.defs)
+			stack, err := evaluator.Eval([]string{test.line})
+			if err != nil {
+				t.Fatalf("Eval(%q) error = %v", test.line, err)
+			}
+			if stack.Oneline(&evaluator.Options) != test.expected {
+				t.Errorf("Eval(%q) = %q, want %q", test.line, stack.Oneline(&evaluator.Options), test.expected)
+			}
+		})
+	}
+}
+
+// Test that a failing line reports an error and leaves the stack as it was
+func TestEvalRestoresStack(t *testing.T) {
+	evaluator := NewEvaluator(DefaultOptions())
+
+	tests := []struct {
+		line       string
+		expected   string
+		shouldFail bool
+	}{
+		{"1 2", "1 2", false},
+		{"+", "3", false},
+		{"4 +  +", "3", true},
+		{"foo", "3", true},
+		{"2 m", "3 2 m", false},
+		{"+", "3 2 m", true},
+		{"x *", "6 m", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.line, func(t *testing.T) {
+			stack, err := evaluator.Eval([]string{test.line})
+			if (err != nil) != test.shouldFail {
+				t.Errorf("Eval(%q) error = %v, want failure %v", test.line, err, test.shouldFail)
+			}
+			if stack.Oneline(&evaluator.Options) != test.expected {
+				t.Errorf("Eval(%q) stack = %q, want %q", test.line, stack.Oneline(&evaluator.Options), test.expected)
+			}
+		})
+	}
+}
+
+// Test that evaluation errors carry a sentinel, the failing token and its position
+func TestEvalErrors(t *testing.T) {
+	tests := []struct {
+		args     []string
+		sentinel error
+		token    string
+		position int
+	}{
+		{[]string{"1 +"}, ErrStackUnderflow, "+", 2},
+		{[]string{"2 m", "3 s +"}, ErrIncompatibleUnits, "+", 5},
+		{[]string{"1.5 3 &"}, ErrNotInteger, "&", 3},
+		{[]string{"0 log"}, ErrDomain, "log", 2},
+		{[]string{"1 0 /"}, ErrDivisionByZero, "/", 3},
+		{[]string{"2 m log"}, ErrDimensionless, "log", 3},
+		{[]string{"2 m sqrt"}, ErrIncompatibleUnits, "sqrt", 3},
+		{[]string{"8 m 0 nroot"}, ErrDomain, "nroot", 4},
+		{[]string{"1 2 foo"}, ErrUnknownToken, "foo", 3},
+		{[]string{"1 <x"}, ErrUnknownRegister, "<x", 2},
+		{[]string{">x"}, ErrStackUnderflow, ">x", 1},
+		{[]string{"1 2", "(3 + foo)"}, ErrUnknownToken, "foo", 6},
+		{[]string{"1", "(2 +)"}, ErrSyntax, ")", 5},
+	}
+
+	for _, test := range tests {
+		t.Run(strings.Join(test.args, " "), func(t *testing.T) {
+			_, err := NewEvaluator(DefaultOptions()).Eval(test.args)
+			if !errors.Is(err, test.sentinel) {
+				t.Fatalf("Eval(%q) error = %v, want %v", test.args, err, test.sentinel)
+			}
+
+			var calcErr *Error
+			if !errors.As(err, &calcErr) {
+				t.Fatalf("Eval(%q) error %T is not *Error", test.args, err)
+			}
+			if calcErr.Token != test.token || calcErr.Position != test.position {
+				t.Errorf("Eval(%q) failed at '%s' (%d), want '%s' (%d)",
+					test.args, calcErr.Token, calcErr.Position, test.token, test.position)
+			}
+		})
+	}
+}
+
+// Test that registers keep values with their units across lines and survive failed lines
+func TestRegisters(t *testing.T) {
+	evaluator := NewEvaluator(DefaultOptions())
+
+	tests := []struct {
+		line       string
+		expected   string
+		registers  string
+		shouldFail bool
+	}{
+		{"3 m >len", "", "len: 3 m", false},
+		{"2 >w <len", "3 m", "len: 3 m\n  w: 2", false},
+		{"<w *", "6 m", "len: 3 m\n  w: 2", false},
+		{">:w <w", "6 m", "len: 3 m\n  w: 2", true},
+		{">:w", "6 m", "len: 3 m", false},
+		{"<len ft", "6 m 9.8425 ft", "len: 3 m", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.line, func(t *testing.T) {
+			stack, err := evaluator.Eval([]string{test.line})
+			if (err != nil) != test.shouldFail {
+				t.Errorf("Eval(%q) error = %v, want failure %v", test.line, err, test.shouldFail)
+			}
+			if stack.Oneline(&evaluator.Options) != test.expected {
+				t.Errorf("Eval(%q) stack = %q, want %q", test.line, stack.Oneline(&evaluator.Options), test.expected)
+			}
+
+			var registers strings.Builder
+			stack.PrintRegisters(&registers, &evaluator.Options)
+			if strings.TrimRight(registers.String(), "\n") != test.registers {
+				t.Errorf("Eval(%q) registers = %q, want %q", test.line, registers.String(), test.registers)
+			}
+		})
+	}
+}
+
+// Test that each evaluator computes inexact results to its own precision, even when run concurrently
+func TestEvaluatorPrecision(t *testing.T) {
+	expected := map[int]string{
+		4:  "1.4142",
+		30: "1.41421356237309504880168872421",
+	}
+
+	var wg sync.WaitGroup
+	for precision, want := range expected {
+		options := DefaultOptions()
+		options.Precision = precision
+		evaluator := NewEvaluator(options)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				stack, err := evaluator.Eval([]string{"2 sqrt"})
+				if err != nil {
+					t.Errorf("Eval(2 sqrt) error = %v", err)
+					return
+				}
+				if got := stack.Oneline(&evaluator.Options); got != want {
+					t.Errorf("precision %d: 2 sqrt = %q, want %q", precision, got, want)
+					return
+				}
+				stack.values = nil
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Test that statistics refuse values they cannot order, as min and max do
+func TestPrintStats(t *testing.T) {
+	tests := []evalTest{
+		{"1+2i 3", "Statistics: domain error: complex value 1+2i - cannot compute statistics\n"},
+		{"1 m 2 s", "Statistics: incompatible units m vs s - cannot compute statistics\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.line, func(t *testing.T) {
+			evaluator := NewEvaluator(DefaultOptions())
+			stack, err := evaluator.Eval([]string{test.line})
+			if err != nil {
+				t.Fatalf("Eval(%q) error = %v", test.line, err)
+			}
+			var output strings.Builder
+			stack.PrintStats(&output, &evaluator.Options)
+			if output.String() != test.expected {
+				t.Errorf("PrintStats(%q) = %q, want %q", test.line, output.String(), test.expected)
+			}
+		})
+	}
+}
+
+// Test that macros expand in place, compose with builtins and bind earlier macros at definition
+func TestMacros(t *testing.T) {
+	evaluator := NewEvaluator(DefaultOptions())
+
+	tests := []struct {
+		line       string
+		expected   string
+		shouldFail bool
+	}{
+		{": sq d * ;", "", false},
+		{"3 sq", "9", false},
+		{": circ sq pi * ;", "9", false},
+		{"p 1 circ t", "3", false},
+		{": sum @+ ;  1 2 sum", "6", false},
+		{": sq d d * * ;  2 sq 2 circ t", "6 8 12", false},
+		{": 3 4 ;", "6 8 12", true},
+		{": tax 1.0825 *", "6 8 12", true},
+		{"tax", "6 8 12", true},
+		{": half 2 / ;  1 m half x p", "6 8 0.5 m", false},
+		{": s @+ ;", "6 8 0.5 m", true},
+		{": ft 12 * ;", "6 8 0.5 m", true},
+		{": d 2 * ;", "6 8 0.5 m", true},
+		{": pi 3 ;", "6 8 0.5 m", true},
+		{": sqrt 2 ** ;", "6 8 0.5 m", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.line, func(t *testing.T) {
+			stack, err := evaluator.Eval([]string{test.line})
+			if (err != nil) != test.shouldFail {
+				t.Errorf("Eval(%q) error = %v, want failure %v", test.line, err, test.shouldFail)
+			}
+			if stack.Oneline(&evaluator.Options) != test.expected {
+				t.Errorf("Eval(%q) stack = %q, want %q", test.line, stack.Oneline(&evaluator.Options), test.expected)
+			}
+		})
+	}
+}
+
+// Test that a macros file may only define macros, and that a bad one adds none
+func TestDefine(t *testing.T) {
+	evaluator := NewEvaluator(DefaultOptions())
+	if err := evaluator.Define([]string{": sq d * ;", ": cube d sq * ;"}); err != nil {
+		t.Fatalf("Define() error = %v", err)
+	}
+	if stack, _ := evaluator.Eval([]string{"3 cube"}); stack.Oneline(&evaluator.Options) != "27" {
+		t.Errorf("3 cube = %q, want 27", stack.Oneline(&evaluator.Options))
+	}
+
+	for _, lines := range [][]string{{": half 2 / ;", "1 2"}, {": half 2 / ;", ": m 3 ;"}} {
+		err := evaluator.Define(lines)
+		if !errors.Is(err, ErrDefinition) {
+			t.Errorf("Define(%q) error = %v, want %v", lines, err, ErrDefinition)
+		}
+		if _, ok := evaluator.Macros()["half"]; ok {
+			t.Errorf("Define(%q) added macro 'half' despite the error", lines)
+		}
+		if evaluator.Stack().size() != 1 {
+			t.Errorf("Define(%q) changed the stack: %q", lines, evaluator.Stack().Oneline(&evaluator.Options))
+		}
+	}
+}
+
+// Test units and constants defined by RPN expressions, as in the config file
+func TestDefineUnitsAndConstants(t *testing.T) {
+	evaluator := NewEvaluator(DefaultOptions())
+	definitions := []struct {
+		name       string
+		definition string
+		define     func(name, definition string) error
+		shouldFail bool
+	}{
+		{"furlong", "201.168 m", func(name, definition string) error { return evaluator.DefineUnit(name, "furlongs", definition) }, false},
+		{"knot", "1852 m/hr", func(name, definition string) error { return evaluator.DefineUnit(name, "knots", definition) }, false},
+		{"m", "2 ft", func(name, definition string) error { return evaluator.DefineUnit(name, "", definition) }, true},
+		{"sqft", "1 ft²", func(name, definition string) error { return evaluator.DefineUnit(name, "", definition) }, true},
+		{"rate", "2 3", func(name, definition string) error { return evaluator.DefineUnit(name, "", definition) }, true},
+		{"g0", "9.80665 m/s²", evaluator.DefineConstant, false},
+		{"12", "1", evaluator.DefineConstant, true},
+	}
+
+	for _, d := range definitions {
+		if err := d.define(d.name, d.definition); (err != nil) != d.shouldFail {
+			t.Errorf("define %s = %q error = %v, want failure %v", d.name, d.definition, err, d.shouldFail)
+		}
+	}
+
+	tests := []evalTest{
+		{"1 furlong ft", "660 ft"},
+		{"10 knot km/hr", "18.52 km/hr"},
+		{"g0 2 s * m/s", "19.6133 m/s"},
+	}
+
+	runEvaluatorTests(t, evaluator, tests)
+}
+
+// Test that the units, constants, aliases and preferred units of an evaluator are not seen by another,
+// and that grouped input groups the display without changing the evaluator's options
+func TestEvaluatorDefinitions(t *testing.T) {
+	evaluator := NewEvaluator(DefaultOptions())
+	if err := evaluator.DefineUnit("furlong", "furlongs", "201.168 m"); err != nil {
+		t.Fatalf("DefineUnit(furlong) error = %v", err)
+	}
+	if err := evaluator.DefineConstant("g0", "9.80665 m/s²"); err != nil {
+		t.Fatalf("DefineConstant(g0) error = %v", err)
+	}
+	if err := evaluator.AliasOperator("^", "**"); err != nil {
+		t.Fatalf("AliasOperator(^) error = %v", err)
+	}
+	if err := evaluator.AliasStackOperation("swap", "x"); err != nil {
+		t.Fatalf("AliasStackOperation(swap) error = %v", err)
+	}
+	if err := evaluator.PreferUnit("m/s", "km/hr"); err != nil {
+		t.Fatalf("PreferUnit(m/s, km/hr) error = %v", err)
+	}
+	if err := evaluator.AliasOperator("^^", "nosuchop"); !errors.Is(err, ErrDefinition) {
+		t.Errorf("AliasOperator(^^, nosuchop) error = %v, want %v", err, ErrDefinition)
+	}
+
+	runEvaluatorTests(t, evaluator, []evalTest{
+		{"1 furlong ft", "660 ft"},
+		{"g0 1 s *", "35.3039 km/hr"},
+		{"2 10 ^", "1024"},
+		{"1 2 swap", "2 1"},
+		{"2 3 ^", "8"},
+	})
+
+	other := NewEvaluator(DefaultOptions())
+	for _, line := range []string{"1 furlong", "g0", "1 2 swap"} {
+		if _, err := other.Eval([]string{line}); !errors.Is(err, ErrUnknownToken) {
+			t.Errorf("Eval(%q) in another evaluator error = %v, want %v", line, err, ErrUnknownToken)
+		}
+	}
+	runEvaluatorTests(t, other, []evalTest{
+		{"2 3 ^", "1"},
+		{"10 m/s", "10 m/s"},
+	})
+
+	grouped := NewEvaluator(DefaultOptions())
+	stack, err := grouped.Eval([]string{"1,000 2"})
+	if err != nil {
+		t.Fatalf("Eval(1,000 2) error = %v", err)
+	}
+	var output strings.Builder
+	stack.Print(&output, &grouped.Options)
+	if !strings.Contains(output.String(), "1,000") || grouped.Options.Group {
+		t.Errorf("Eval(1,000 2) printed %q with Group %v, want 1,000 with Group false", output.String(), grouped.Options.Group)
+	}
+}
+
+// Test that trigonometric functions convert angle units and inverse functions return radians
+func TestAngles(t *testing.T) {
+	tests := []struct {
+		line       string
+		expected   string
+		shouldFail bool
+	}{
+		{"30 deg sin", "0.5", false},
+		{"pi 6 / sin", "0.5", false},
+		{"45 deg tan", "1", false},
+		{"200 grad cos", "-1", false},
+		{"0.5 asin deg", "30 deg", false},
+		{"3 m 3 m atan2 deg", "45 deg", false},
+		{"1 asin deg", "90 deg", false},
+		{"1 1 atan2 deg", "45 deg", false},
+		{"-1 1 atan2 deg", "-45 deg", false},
+		{"1 -1 atan2 grad", "150 grad", false},
+		{"-0.5 acos deg", "120 deg", false},
+		{"1 atan arcmin", "2700 arcmin", false},
+		{"0.3 asin deg", "17.4576 deg", false},
+		{"-1-1i arg deg", "-135 deg", false},
+		{"90 arcmin deg", "1.5 deg", false},
+		{"2 m sin", "", true},
+		{"1 deg asin", "", true},
+		{"1 deg sinh", "", true},
+		{"90 deg tan", "", true},
+		{"-270 deg tan", "", true},
+		{"pi 2 / tan", "", true},
+		{"180 deg tan", "0", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.line, func(t *testing.T) {
+			evaluator := NewEvaluator(DefaultOptions())
+			stack, err := evaluator.Eval([]string{test.line})
+			if (err != nil) != test.shouldFail {
+				t.Errorf("Eval(%q) error = %v, want failure %v", test.line, err, test.shouldFail)
+			}
+			if stack.Oneline(&evaluator.Options) != test.expected {
+				t.Errorf("Eval(%q) stack = %q, want %q", test.line, stack.Oneline(&evaluator.Options), test.expected)
+			}
+		})
+	}
+}
+
+// Test complex values with units, polar display and operators that reject complex values
+func TestComplexValues(t *testing.T) {
+	tests := []struct {
+		line       string
+		polar      bool
+		expected   string
+		shouldFail bool
+	}{
+		{"10 V 3+4i Ω /", false, "1.2-1.6i A", false},
+		{"10 V 3+4i Ω / abs", false, "2 A", false},
+		{"10 V 3+4i Ω /", true, "2∠-53.1301° A", false},
+		{"3+4i arg deg", false, "53.1301 deg", false},
+		{"1+i 2 m *", false, "2+2i m", false},
+		{"1+i 1-i +", false, "2", false},
+		{"1+i t", false, "", true},
+		{"1+i 2 &", false, "", true},
+		{"1+i 2 %", false, "", true},
+		{"1+i sin", false, "", true},
+		{"1+i mini", false, "", true},
+		{"1+i max!", false, "", true},
+		{"1+i 2 mini", false, "", true},
+		{"2 1+i max", false, "", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.line, func(t *testing.T) {
+			evaluator := NewEvaluator(DefaultOptions())
+			evaluator.Options.Polar = test.polar
+			stack, err := evaluator.Eval([]string{test.line})
+			if (err != nil) != test.shouldFail {
+				t.Errorf("Eval(%q) error = %v, want failure %v", test.line, err, test.shouldFail)
+			}
+			if stack.Oneline(&evaluator.Options) != test.expected {
+				t.Errorf("Eval(%q) stack = %q, want %q", test.line, stack.Oneline(&evaluator.Options), test.expected)
+			}
+		})
+	}
+}
+
+// Test that the JSON output keeps values exact and includes the representations selected in the options
+func TestPrintJSON(t *testing.T) {
+	options := DefaultOptions()
+	options.ShowHex = true
+	options.ShowFactor = true
+
+	tests := []struct {
+		line     string
+		expected []jsonValue
+	}{
+		{"12", []jsonValue{{Numerator: "12", Denominator: "1", Decimal: "12", Dimensions: map[string]int{},
+			Hex: "0xc", Factors: []string{"2^2", "3"}}}},
+		{"3 m 4 s /", []jsonValue{{Numerator: "3", Denominator: "4", Decimal: "0.75", Units: "m/s",
+			Dimensions: map[string]int{"length": 1, "time": -1}}}},
+		{"1 3 / 2-0.5i", []jsonValue{
+			{Numerator: "1", Denominator: "3", Decimal: "0.3333", Dimensions: map[string]int{}},
+			{Numerator: "2", Denominator: "1", ImaginaryNumerator: "-1", ImaginaryDenominator: "2", Decimal: "2-0.5i",
+				Dimensions: map[string]int{}},
+		}},
+		{"5ft11in", []jsonValue{{Numerator: "71", Denominator: "1", Decimal: "71", Units: "in",
+			Dimensions: map[string]int{"length": 1}, Hex: "0x47", Factors: []string{"71"}}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.line, func(t *testing.T) {
+			stack, err := NewEvaluator(options).Eval([]string{test.line})
+			if err != nil {
+				t.Fatalf("Eval(%q) error = %v", test.line, err)
+			}
+
+			var output strings.Builder
+			if err := stack.PrintJSON(&output, &options); err != nil {
+				t.Fatalf("PrintJSON error = %v", err)
+			}
+
+			var decoded jsonStack
+			if err := json.Unmarshal([]byte(output.String()), &decoded); err != nil {
+				t.Fatalf("PrintJSON output %q is not valid JSON: %v", output.String(), err)
+			}
+			if !reflect.DeepEqual(decoded.Stack, test.expected) {
+				t.Errorf("PrintJSON(%q) = %+v, want %+v", test.line, decoded.Stack, test.expected)
+			}
+		})
+	}
+}
+
+// Test that infix expressions compile to the equivalent RPN and evaluate to the same values
+func TestInfix(t *testing.T) {
+	tests := []struct {
+		expression string
+		rpn        string
+		expected   string
+	}{
+		{"(3 ft + 2 in) * 4 in m", "3 ft 2 in + 4 in m *", "1.0556 ft²"},
+		{"1 + 2 * 3", "1 2 3 * +", "7"},
+		{"(1 + 2) * 3", "1 2 + 3 *", "9"},
+		{"10 - 4 - 3", "10 4 - 3 -", "3"},
+		{"-2**2", "2 2 ** chs", "-4"},
+		{"2**3**2", "2 3 2 ** **", "512"},
+		{"2**-1", "2 1 chs **", "0.5"},
+		{"sqrt(16) * atan2(1, 1) deg", "16 sqrt 1 1 atan2 deg *", "180 deg"},
+		{"pow(2,10) - 4!", "2 10 pow 4 ! -", "1000"},
+		{"2*1e-3", "2 1e-3 *", "0.002"},
+		{"10 m / s", "10 m 1 s /", "10 m/s"},
+		{"10 m/s*2", "10 m/s 2 *", "20 m/s"},
+		{"((1 + 2) m) ft", "1 2 + m ft", "9.8425 ft"},
+		{"1 << 4 | 1 & 3", "1 4 << 1 3 & |", "17"},
+		{"(3+4i) * (1-2i)", "3+4i 1-2i *", "11-2i"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			compiled, err := builtInDefinitions.compileInfix(test.expression)
+			if err != nil {
+				t.Fatalf("compileInfix(%q) error = %v", test.expression, err)
+			}
+			if strings.Join(compiled, " ") != test.rpn {
+				t.Errorf("compileInfix(%q) = %q, want %q", test.expression, strings.Join(compiled, " "), test.rpn)
+			}
+
+			options := DefaultOptions()
+			options.Infix = true
+			evaluator := NewEvaluator(options)
+			stack, err := evaluator.Eval([]string{test.expression})
+			if err != nil {
+				t.Fatalf("Eval(%q) error = %v", test.expression, err)
+			}
+			if stack.Oneline(&evaluator.Options) != test.expected {
+				t.Errorf("Eval(%q) = %q, want %q", test.expression, stack.Oneline(&evaluator.Options), test.expected)
+			}
+		})
+	}
+
+	errorTests := []struct {
+		expression string
+		err        error
+		token      string
+	}{
+		{"(1 + 2", ErrSyntax, "2"},
+		{"1 + * 2", ErrSyntax, "*"},
+		{"1 2", ErrSyntax, "2"},
+		{"sqrt(1, 2)", ErrSyntax, ","},
+		{"atan2(1)", ErrSyntax, ")"},
+		{"2 + foo", ErrUnknownToken, "foo"},
+	}
+
+	for _, test := range errorTests {
+		t.Run(test.expression, func(t *testing.T) {
+			_, err := builtInDefinitions.compileInfix(test.expression)
+			var evalErr *Error
+			if !errors.As(err, &evalErr) || !errors.Is(err, test.err) || evalErr.Token != test.token {
+				t.Errorf("compileInfix(%q) error = %v, want %v at '%s'", test.expression, err, test.err, test.token)
+			}
+		})
+	}
+
+	// Without the infix option, parentheses in units leave an argument RPN
+	runEvalTests(t, DefaultOptions(), []evalTest{
+		{"1 J/(kg·K)", "1 m²/s²·K"},
+		{"3 m 1.5W/(m·K) .", "4.5 W/K"},
+		{"2 * (3 + 4)", "14"},
+		{"(1 + 2) m", "3 m"},
+	})
+}
+
+// Test that a number with attached units is the same as the number followed by the units
+func TestNumberWithUnits(t *testing.T) {
+	tests := []evalTest{
+		{"5kg", "5 kg"},
+		{"3.5ft", "3.5 ft"},
+		{"12V", "12 V"},
+		{"1.5hr", "1:30:00 hr"},
+		{"-20C", "-20 °C"},
+		{"2e3m", "2000 m"},
+		{"5m/s", "5 m/s"},
+		{"4Mm", "4 Mm"},
+		{"4M", "4194304"},
+		{"2Km", "2048 m"},
+	}
+
+	runEvalTests(t, DefaultOptions(), tests)
+
+	for _, token := range []string{"5xyz", "3ft2"} {
+		if _, err := NewEvaluator(DefaultOptions()).Eval([]string{token}); !errors.Is(err, ErrUnknownToken) {
+			t.Errorf("Eval(%q) error = %v, want %v", token, err, ErrUnknownToken)
+		}
+	}
+}
+
+// Test that a denominator may be grouped in parentheses or divided again, and means the same units
+func TestDenominators(t *testing.T) {
+	expected, _ := builtInDefinitions.parseUnits("J/kg·K")
+	for _, input := range []string{"J/(kg·K)", "J/kg/K", "J/(kg*K)"} {
+		if units, ok := builtInDefinitions.parseUnits(input); !ok || !reflect.DeepEqual(units, expected) {
+			t.Errorf("parseUnits(%q) = %v, %v, want %v", input, units, ok, expected)
+		}
+	}
+	for _, input := range []string{"J/(kg·K", "J/(kg/K)", "J/kg)", "J(kg)", "J/()", "J/(kg)·K"} {
+		if _, ok := builtInDefinitions.parseUnits(input); ok {
+			t.Errorf("parseUnits(%q) succeeded, want no units", input)
+		}
+	}
+
+	tests := []evalTest{
+		{"1 m/s/s", "1 m/s²"},
+		{"60 mi/hr/s", "0.0167 mi/s²"},
+		{"1 /s/m", "1 /m·s"},
+	}
+
+	runEvalTests(t, DefaultOptions(), tests)
+}
+
+// Test that currencies and temperatures convert as rates inside compound units and powers
+func TestCompoundDynamicUnits(t *testing.T) {
+	evaluator := NewEvaluator(DefaultOptions())
+	evaluator.Currency = func(amount *Number, from, to string) (*Number, error) {
+		// 1 usd = 0.5 eur
+		if from == "USD" && to == "EUR" {
+			return mul(amount, newRationalNumber(1, 2)), nil
+		} else if from == "EUR" && to == "USD" {
+			return mul(amount, newNumber(2)), nil
+		}
+		return nil, fmt.Errorf("no rate for %s -> %s", from, to)
+	}
+
+	tests := []evalTest{
+		{"10 usd eur", "5 eur"},
+		{"3.50 $/gal €/l", "0.4623 €/l"},
+		{"0.12 usd/kg eur/lb", "0.0272 eur/lb"},
+		{"10 l/usd l/eur", "20 l/eur"},
+		{"4 usd² eur²", "1 eur²"},
+		{"68 F C", "20 °C"},
+		{"9 °F/hr °C/hr", "5 °C/hr"},
+		{"1 °C² °F²", "3.24 °F²"},
+		{"2 A/°C 1 A/°F +", "3.8 A/°C"},
+	}
+
+	runEvaluatorTests(t, evaluator, tests)
+}
+
+// Test that all SI units take prefixes, and that existing units win over prefixed names
+func TestPrefixedUnits(t *testing.T) {
+	tests := []evalTest{
+		{"1500 ms s", "1.5 s"},
+		{"3 μs ns", "3000 ns"},
+		{"2 kJ J", "2000 J"},
+		{"3 kN N", "3000 N"},
+		{"5 mΩ Ω", "0.005 Ω"},
+		{"2000 ohm kohm Ω", "2000 Ω"},
+		{"1 min s", "60 s"},
+		{"2 m·s", "2 m·s"},
+	}
+
+	runEvalTests(t, DefaultOptions(), tests)
+
+	// Prefixing in would make min milli-inches, but the existing minutes win
+	saved, savedPrefixes := UNITS, UNITS_FOR_PREFIXES
+	defer func() { UNITS, UNITS_FOR_PREFIXES = saved, savedPrefixes }()
+	UNITS = make(map[string]Unit, len(saved))
+	for name, unit := range saved {
+		UNITS[name] = unit
+	}
+	UNITS_FOR_PREFIXES = []string{"in"}
+	generatePrefixedUnits()
+
+	if UNITS["min"][Time].name != "min" || UNITS["kin"][Length].name != "kin" {
+		t.Errorf("prefixing 'in' gave min = %v, kin = %v", UNITS["min"], UNITS["kin"])
+	}
+}
+
+// Test that auto-prefix rescales values in SI units only, and that prefixed derived units display with their prefix
+func TestAutoPrefix(t *testing.T) {
+	tests := []evalTest{
+		{"1 kW", "1 kW"},
+		{"2 kohm", "2 kΩ"},
+		{"1 lb·ft²/s³", "1 lb·ft²/s³"},
+	}
+
+	runEvalTests(t, DefaultOptions(), tests)
+
+	prefixed := []evalTest{
+		{"0.000047 A", "47 μA"},
+		{"1234567 W", "1.2346 MW"},
+		{"1500 g", "1.5 kg"},
+		{"0.5 l", "500 ml"},
+		{"100 kW", "100 kW"},
+		{"0.002 kJ", "2 J"},
+		{"0 A", "0 A"},
+		{"5280 ft", "5280 ft"},
+		{"2000 usd", "2000 usd"},
+		{"1000 m/s", "1000 m/s"},
+	}
+
+	options := DefaultOptions()
+	options.AutoPrefix = true
+	runEvalTests(t, options, prefixed)
+}
+
+// Test that products of SI units display with derived units only where those replace base units
+func TestSimplifyUnits(t *testing.T) {
+	tests := []evalTest{
+		{"3 V 2 A .", "6 W"},
+		{"2 W 3 s .", "6 J"},
+		{"6 J 2 s /", "3 W"},
+		{"2 N 3 m .", "6 J"},
+		{"1 kg·m²/s²·A", "1 V·s"},
+		{"2 V 3 s .", "6 V·s"},
+		{"1 kg·m/s", "1 N·s"},
+		{"1 kg/s²", "1 kg/s²"},
+		{"1 kg/s³", "1 kg/s³"},
+		{"9.8 m/s²", "9.8 m/s²"},
+		{"3 m/s 2 s /", "1.5 m/s²"},
+		{"1 m²/s²", "1 m²/s²"},
+		{"3 m/s 3 m/s .", "9 m²/s²"},
+		{"3 V 2 m /", "1.5 V/m"},
+		{"1 J/K", "1 J/K"},
+		{"1 g·m²/s²·A", "1 g·m²/s²·A"},
+		{"1 lb·ft/s", "1 lb·ft/s"},
+		{"1 m/s", "1 m/s"},
+	}
+
+	runEvalTests(t, DefaultOptions(), tests)
+}
+
+// Test that values display in the preferred unit of their dimensions unless base units are requested
+func TestPreferUnit(t *testing.T) {
+	evaluator := NewEvaluator(DefaultOptions())
+	if err := evaluator.PreferUnit("m/s", "km/hr"); err != nil {
+		t.Fatalf("PreferUnit(m/s, km/hr) error = %v", err)
+	}
+	if err := evaluator.PreferUnit("J", "W"); !errors.Is(err, ErrIncompatibleUnits) {
+		t.Errorf("PreferUnit(J, W) error = %v, want %v", err, ErrIncompatibleUnits)
+	}
+	if err := evaluator.PreferUnit("J", "usd"); err == nil {
+		t.Errorf("PreferUnit(J, usd) succeeded, want error")
+	}
+	if err := evaluator.PreferUnit("J", ""); !errors.Is(err, ErrDefinition) {
+		t.Errorf("PreferUnit(J, \"\") error = %v, want %v", err, ErrDefinition)
+	}
+	if err := evaluator.PreferUnit("", "J"); !errors.Is(err, ErrDefinition) {
+		t.Errorf("PreferUnit(\"\", J) error = %v, want %v", err, ErrDefinition)
+	}
+
+	tests := []evalTest{
+		{"10 m/s", "36 km/hr"},
+		{"1 mi/hr", "1.6093 km/hr"},
+		{"10 m", "10 m"},
+	}
+
+	runEvaluatorTests(t, evaluator, tests)
+
+	evaluator.Options.Base = true
+	runEvaluatorTests(t, evaluator, []evalTest{{"10 m/s", "10 m/s"}})
+	runEvalTests(t, DefaultOptions(), []evalTest{{"10 m/s", "10 m/s"}})
+}
+
+// Test the mole, candela, lumen, lux and katal, and the Avogadro and gas constants
+func TestAmountAndLuminousUnits(t *testing.T) {
+	tests := []evalTest{
+		{"R", "8.3145 J/K·mol"},
+		{"R 300 K . 2 mol .", "4988.6776 J"},
+		{"1 mol NA . 6.02214076e23 /", "1"},
+		{"18 g/mol 2 mol .", "36 g"},
+		{"0.5 mol/l 2 l .", "1 mol"},
+		{"250 mmol mol", "0.25 mol"},
+		{"1 mol 2 s /", "0.5 kat"},
+		{"3 mkat", "3 mkat"},
+		{"800 lm 2 m 2 pow /", "200 lx"},
+		{"5 klx lx", "5000 lx"},
+		{"1 lm 1 W /", "1 lm/W"},
+	}
+
+	runEvalTests(t, DefaultOptions(), tests)
+}
+
+// Test that bits and bytes take SI and IEC prefixes, and that bare binary suffixes are still numbers
+func TestInformationUnits(t *testing.T) {
+	tests := []evalTest{
+		{"4G", "4294967296"},
+		{"4GiB", "4 GiB"},
+		{"1 KiB B", "1024 B"},
+		{"1 MB bit", "8000000 bit"},
+		{"1 GB GiB", "0.9313 GiB"},
+		{"100 Mbps MB/s", "12.5 MB/s"},
+		{"1 bit 2 s /", "0.5 bps"},
+		{"4 GiB 100 Mbps / s", "343.5974 s"},
+		{"3 MiB/s 2 s .", "6 MiB"},
+	}
+
+	runEvalTests(t, DefaultOptions(), tests)
+
+	if _, ok := builtInDefinitions.parseUnits("dB"); ok {
+		t.Errorf("parseUnits(dB) succeeded, want no fractional byte units")
+	}
+}
+
+// Test conversions between the pressure, energy, speed, force, frequency, power, time, length, mass and volume units
+func TestUnitCatalog(t *testing.T) {
+	tests := []evalTest{
+		{"1 psi Pa", "6894.7573 Pa"},
+		{"1 atm psi", "14.6959 psi"},
+		{"760 mmHg atm", "1.0000 atm"},
+		{"1013.25 mbar atm", "1 atm"},
+		{"1 kcal J", "4184 J"},
+		{"1 BTU J", "1055.0559 J"},
+		{"1 kWh MJ", "3.6 MJ"},
+		{"1 MeV 1 eV /", "1000000"},
+		{"60 mph kph", "96.5606 km/hr"},
+		{"1 kn m/s", "0.5144 m/s"},
+		{"1 lbf N", "4.4482 N"},
+		{"1 kgf N", "9.8067 N"},
+		{"100000 dyn", "100000 dyn"},
+		{"3000 rpm Hz", "50 Hz"},
+		{"2 kHz", "2 kHz"},
+		{"1 hp W", "745.6999 W"},
+		{"1 yr day", "365.25 day"},
+		{"2 wk day", "14 day"},
+		{"1 ly au", "63241.0771 au"},
+		{"1 nmi m", "1852 m"},
+		{"2 tonne kg", "2000 kg"},
+		{"10 st lb", "140 lb"},
+		{"1 ukgal ukpt", "8 ukpt"},
+		{"1 ukgal gal", "1.2009 gal"},
+		// parts named for other units are shown in SI units once the dimensions change
+		{"1 kWh 1 hr /", "1000 W"},
+		{"1 psi 1 in 2 pow .", "4.4482 N"},
+		{"2 rpm 3 min .", "6"},
+	}
+
+	runEvalTests(t, DefaultOptions(), tests)
+}
+
+// Test that a units file defines units in any order, and that a bad one reports its line and adds nothing
+func TestLoadUnits(t *testing.T) {
+	definitions := `
+# forward references are allowed
+rack = 42 RU     # a full rack
+RU = 1.75 in
+blip* = 2 wk
+`
+	evaluator := NewEvaluator(DefaultOptions())
+	if err := evaluator.LoadUnits(strings.NewReader(definitions), "units"); err != nil {
+		t.Fatalf("LoadUnits() error = %v", err)
+	}
+
+	tests := []evalTest{
+		{"1 rack in", "73.5 in"},
+		{"2 RU mm", "88.9 mm"},
+		{"1 kblip day", "14000 day"},
+		{"3 blip", "3 blip"},
+	}
+	runEvaluatorTests(t, evaluator, tests)
+
+	failures := []struct {
+		definitions string
+		expected    string
+	}{
+		{"ua = 2 ub\nub = 3 uc\nuc = 4 ua\n", "units:1: invalid definition: cycle in unit definitions: ua -> ub -> uc -> ua"},
+		{"ud = 2 m\nud = 3 m\n", "units:2: invalid definition: unit 'ud' already defined at line 1"},
+		{"ft = 2 m\n", "units:1: invalid definition: unit 'ft' already exists"},
+		{"x = 2 m\n", "units:1: invalid definition: unit 'x' conflicts with stack operation 'x'"},
+		{"\nsqrt = 2 m\n", "units:2: invalid definition: unit 'sqrt' conflicts with operator 'sqrt'"},
+		{"ue 2 m\n", "units:1: invalid definition: expected name = definition, found 'ue 2 m'"},
+		{"uf = 2 nosuchunit\n", "units:1: unit 'uf': unrecognized argument 'nosuchunit' (token 2)"},
+	}
+	for _, test := range failures {
+		t.Run(test.expected, func(t *testing.T) {
+			err := NewEvaluator(DefaultOptions()).LoadUnits(strings.NewReader(test.definitions), "units")
+			if err == nil || err.Error() != test.expected {
+				t.Errorf("LoadUnits(%q) error = %v, want %q", test.definitions, err, test.expected)
+			}
+			if !errors.Is(err, ErrDefinition) && !errors.Is(err, ErrUnknownToken) {
+				t.Errorf("LoadUnits(%q) error = %v, want ErrDefinition", test.definitions, err)
+			}
+		})
+	}
+
+	defs := evaluator.defs
+	units, prefixed, dimensions := len(defs.units), len(defs.prefixed), len(defs.dimensions)
+	partial := "widget* !\ncrate = 2 widget\nuh = 2 nosuchunit\n"
+	if err := evaluator.LoadUnits(strings.NewReader(partial), "units"); err == nil {
+		t.Fatalf("LoadUnits(%q) succeeded, want error", partial)
+	}
+	for _, name := range []string{"widget", "kwidget", "crate"} {
+		if _, ok := defs.unit(name); ok {
+			t.Errorf("LoadUnits(%q) failed but defined unit %q", partial, name)
+		}
+	}
+	if len(defs.units) != units || len(defs.prefixed) != prefixed || len(defs.dimensions) != dimensions {
+		t.Errorf("LoadUnits(%q) failed but left %d units, %d prefixed units, %d dimensions, want %d, %d, %d",
+			partial, len(defs.units), len(defs.prefixed), len(defs.dimensions), units, prefixed, dimensions)
+	}
+}
+
+// Test that a primitive unit makes a new dimension, checked and converted like the built-in ones
+func TestDefineBaseUnit(t *testing.T) {
+	definitions := `
+gizmo* !           # a new dimension
+box = 12 gizmo
+`
+	evaluator := NewEvaluator(DefaultOptions())
+	if err := evaluator.LoadUnits(strings.NewReader(definitions), "units"); err != nil {
+		t.Fatalf("LoadUnits() error = %v", err)
+	}
+
+	tests := []evalTest{
+		{"3 box gizmo", "36 gizmo"},
+		{"6000 gizmo 1 min / kgizmo/s", "0.1 kgizmo/s"},
+		{"10 $ 4 box /", "2.5 $/box"},
+		{"10 $ 4 box / $/gizmo", "0.2083 $/gizmo"},
+		{"1 box 6 gizmo +", "1.5 box"},
+	}
+	runEvaluatorTests(t, evaluator, tests)
+
+	if _, err := evaluator.Eval([]string{"1 gizmo 1 kg +"}); !errors.Is(err, ErrIncompatibleUnits) {
+		t.Errorf("Eval(1 gizmo 1 kg +) error = %v, want ErrIncompatibleUnits", err)
+	}
+
+	gizmo := evaluator.defs.units["gizmo"][NumDimension]
+	if name := evaluator.defs.dimensionName(NumDimension); gizmo.name != "gizmo" || name != "gizmo" {
+		t.Errorf("dimension %d = %q with unit %q, want gizmo", NumDimension, name, gizmo.name)
+	}
+
+	if err := evaluator.DefineBaseUnit("gizmo", "gizmo"); err == nil || err.Error() != "invalid definition: unit 'gizmo' already exists" {
+		t.Errorf("DefineBaseUnit(gizmo) error = %v, want already exists", err)
+	}
+	if err := evaluator.DefineBaseUnit("dup", "dup"); err == nil || err.Error() != "invalid definition: unit 'dup' conflicts with stack operation 'dup'" {
+		t.Errorf("DefineBaseUnit(dup) error = %v, want conflict", err)
+	}
+}
+
+// Test that areas and volumes are powers of length, in any combination
+func TestAreaAndVolume(t *testing.T) {
+	tests := []evalTest{
+		{"1 acre m²", "4046.8564 m²"},
+		{"1 acre ha", "0.4047 ha"},
+		{"1 gal in³", "231 in³"},
+		{"1 l cm³", "1000 cm³"},
+		{"1 l/s m³/s", "0.001 m³/s"},
+		{"2 l/s ft³/min", "4.2378 ft³/min"},
+		{"1 acre·ft gal", "325851.4286 gal"},
+		{"1 acre 1 ft * gal", "325851.4286 gal"},
+		{"1 ha·m m³", "10000 m³"},
+		{"1 ha 1 m *", "10000 m³"},
+		{"1 l 1 m /", "0.001 m²"},
+		{"1 cm³ 1 l +", "1001 cm³"},
+		{"1 acre 2 pow", "1 acre²"},
+		{"1 in·ft in²", "12 in²"},
+		{"1 kWh/hr", "1 kW"},
+		{"1 J/l", "1 kPa"},
+	}
+	runEvalTests(t, DefaultOptions(), tests)
+
+	if _, ok := builtInDefinitions.parseUnits("m/ft"); ok {
+		t.Errorf("parseUnits(m/ft) succeeded, want no units for powers that cancel")
+	}
+}
+
+// Test that roots and rational powers apply to units when every dimension keeps an integral power
+func TestUnitRoots(t *testing.T) {
+	tests := []evalTest{
+		{"4 m² sqrt", "2 m"},
+		{"9 m^2/s^2 0.5 **", "3 m/s"},
+		{"2 m 3 pow 2 3 / **", "4 m²"},
+		{"9 m² -0.5 **", "0.3333 /m"},
+		{"-8 m³ cbrt", "-2 m"},
+		{"8 l cbrt", "0.2 m"},
+		{"16 m⁴ 4 nroot", "2 m"},
+		{"4 ha sqrt", "200 m"},
+		{"1 acre sqrt ft", "208.7103 ft"},
+		{"-8 cbrt", "-2"},
+		{"nroot(81, 4)", "3"},
+	}
+	runEvalTests(t, DefaultOptions(), tests)
+}
+
+// Test that composite units display values split across units and parse values like 5ft11in
+func TestCompositeUnits(t *testing.T) {
+	tests := []evalTest{
+		{"1.8 m ft+in", "5 ft 10.8661 in"},
+		{"2.3 kg lb+oz", "5 lb 1.1301 oz"},
+		{"1e6 s d+hr+min+s", "11 day 13 hr 46 min 40 s"},
+		{"90061 s d+h+min+s", "1 day 1 hr 1 min 1 s"},
+		{"1 mi yd+ft+in", "1760 yd"},
+		{"11.99999 in ft+in", "1 ft"},
+		{"0 m ft+in", "0 in"},
+		{"-1.8 m ft+in", "-5 ft 10.8661 in"},
+		{"5ft11in in", "71 in"},
+		{`5'11"`, "5 ft 11 in"},
+		{"-5ft11in in", "-71 in"},
+		{"-0ft6in in", "-6 in"},
+		{"-0ft6in", "-6 in"},
+		{"2lb3oz 1 oz +", "2 lb 4 oz"},
+		{"5ft11in cm", "180.34 cm"},
+		{"1.8 m ft+in 2 *", "11 ft 9.7323 in"},
+		{"1 ft+in", "1 ft"},
+		{"1.5ft+in", "1 ft 6 in"},
+		{"1 deg+arcmin", "1 deg"},
+		{"5ft11in d *", "5041 in²"},
+		{"5ft11in 2 s /", "35.5 in/s"},
+		{"1+i ft+in", "12+12i in"},
+	}
+	runEvalTests(t, DefaultOptions(), tests)
+
+	// Composite units need two or more units of one family, largest first
+	for _, input := range []string{"in+ft", "ft+ft", "ft+kg", "ft+", "ft+in·s"} {
+		if _, ok := builtInDefinitions.parseUnits(input); ok {
+			t.Errorf("parseUnits(%q) succeeded, want no composite unit", input)
+		}
+	}
+	for _, input := range []string{"11in5ft", "5ft", "5ft-11in", "5ft11"} {
+		if _, _, ok := parseCompositeValue(input); ok {
+			t.Errorf("parseCompositeValue(%q) succeeded, want no value", input)
+		}
+	}
+}
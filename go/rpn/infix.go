@@ -0,0 +1,305 @@
+// Copyright 2024 Mike Carlton
+// Released under terms of the MIT License:
+//   http://www.opensource.org/licenses/mit-license.php
+
+package rpn
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Infix expressions are compiled to RPN tokens, so they evaluate with exactly the operators,
+// units and conversions of RPN input, e.g. "(3 ft + 2 in) * 4 in m" becomes "3 ft 2 in + 4 in m *"
+//
+// Precedence, lowest first: | ^ & (<< >>) (+ -) (* / % •) (unary - + ~) ** (postfix ! and units);
+// ** is right associative, so -2**2 is -4 and 2**3**2 is 512. Units apply to the operand before them,
+// a unit on its own is one of that unit (10 m / s), and functions are the named operators: sqrt(2), atan2(1, 2)
+
+// infixSymbols are the operator and punctuation tokens, longest first
+var infixSymbols = []string{"**", "<<", ">>", "+", "-", "*", "/", "%", "&", "|", "^", "~", "!", "•", "(", ")", ","}
+
+// infixPrecedence gives the precedence of the left-associative binary operators
+var infixPrecedence = map[string]int{
+	"|":  1,
+	"^":  2,
+	"&":  3,
+	"<<": 4, ">>": 4,
+	"+": 5, "-": 5,
+	"*": 6, "/": 6, "%": 6, "•": 6,
+}
+
+// isInfix reports whether an argument must be an infix expression, as it has parentheses outside of units;
+// RPN tokens have them only in a denominator (J/(kg·K), 4.2kJ/(kg·K))
+func (d *definitions) isInfix(argument string) bool {
+	for _, field := range strings.Fields(argument) {
+		if strings.ContainsAny(field, "()") && !d.isUnits(field) && !d.isOperand(field) {
+			return true
+		}
+	}
+	return false
+}
+
+// isOperand reports whether token is a value: a number (with any attached units), constant, register recall or ticker
+func (d *definitions) isOperand(token string) bool {
+	if _, ok := parseNumber(token); ok {
+		return true
+	} else if _, ok := parseBase60(token); ok {
+		return true
+	} else if _, ok := parseIPv4(token); ok {
+		return true
+	} else if _, ok := parseComplex(token); ok {
+		return true
+	} else if _, _, ok := d.parseNumberWithUnits(token); ok {
+		return true
+	} else if _, ok := d.constant(token); ok {
+		return true
+	} else if match := registerPattern.FindStringSubmatch(token); match != nil && match[1] == "<" {
+		return true
+	}
+	_, ok := IsTickerSymbol(token)
+	return ok
+}
+
+func (d *definitions) isUnits(token string) bool {
+	_, ok := d.parseUnits(token)
+	return ok
+}
+
+// isFunction reports whether token names an operator that can be called as name(arguments)
+func (d *definitions) isFunction(token string) bool {
+	if _, ok := OPERATOR[d.operator(token)]; !ok {
+		return false
+	}
+	return strings.IndexFunc(token, func(r rune) bool { return !('a' <= r && r <= 'z' || '0' <= r && r <= '9') }) < 0
+}
+
+// tokenizeInfix splits an expression into symbols and the longest words that are operands, units or functions,
+// so "2*1e-3" is 2 * 1e-3 and "10 m/s*2" is 10 m/s * 2; ',' only separates function arguments
+func (d *definitions) tokenizeInfix(expression string) ([]string, error) {
+	var tokens []string
+	for _, field := range strings.Fields(expression) {
+		word, position := "", len(tokens) // text since the last symbol and its position, for errors
+		for field != "" {
+			symbol := ""
+			for _, s := range infixSymbols {
+				if strings.HasPrefix(field, s) {
+					symbol = s
+					break
+				}
+			}
+			if symbol != "" {
+				tokens = append(tokens, symbol)
+				field = field[len(symbol):]
+				word, position = "", len(tokens)
+				continue
+			}
+
+			// Words end before any punctuation
+			limit := len(field)
+			if end := strings.IndexAny(field, "(),"); end >= 0 {
+				limit = end
+			}
+			token := ""
+			for end := limit; end > 0; end-- {
+				// parseUnits allows a trailing separator, which here is an operator: m/s*2 is m/s * 2
+				prefix := field[:end]
+				if d.isOperand(prefix) || d.isUnits(prefix) && strings.TrimRight(prefix, "*/.·") == prefix || d.isFunction(prefix) {
+					token = prefix
+					break
+				}
+			}
+			if token == "" {
+				word += field[:limit]
+				return nil, &Error{Token: word, Position: position + 1, Err: fmt.Errorf("%w '%s'", ErrUnknownToken, word)}
+			}
+
+			tokens = append(tokens, token)
+			field = field[len(token):]
+			word += token
+		}
+	}
+
+	return tokens, nil
+}
+
+type infixParser struct {
+	defs   *definitions
+	tokens []string
+	next   int
+	output []string
+}
+
+// compileInfix compiles an infix expression with the units, constants and operators of d to the equivalent RPN tokens
+// Errors are returned as *Error, with the position of the token in the expression
+func (d *definitions) compileInfix(expression string) ([]string, error) {
+	tokens, err := d.tokenizeInfix(expression)
+	if err != nil || len(tokens) == 0 {
+		return nil, err
+	}
+
+	p := &infixParser{defs: d, tokens: tokens}
+	if err := p.expression(1); err != nil {
+		return nil, err
+	}
+	if p.next < len(p.tokens) {
+		return nil, p.error("unexpected '%s'", p.peek())
+	}
+	return p.output, nil
+}
+
+func (p *infixParser) peek() string {
+	if p.next < len(p.tokens) {
+		return p.tokens[p.next]
+	}
+	return ""
+}
+
+func (p *infixParser) advance() string {
+	token := p.peek()
+	p.next++
+	return token
+}
+
+func (p *infixParser) emit(tokens ...string) {
+	p.output = append(p.output, tokens...)
+}
+
+// error reports a syntax error at the current token, or at the last one at the end of the expression
+func (p *infixParser) error(format string, args ...any) error {
+	position := min(p.next, len(p.tokens)-1)
+	token := ""
+	if position >= 0 {
+		token = p.tokens[position]
+	}
+	return &Error{Token: token, Position: position + 1, Err: fmt.Errorf("%w: %s", ErrSyntax, fmt.Sprintf(format, args...))}
+}
+
+func (p *infixParser) expect(token string) error {
+	if p.peek() != token {
+		if p.peek() == "" {
+			return p.error("missing '%s'", token)
+		}
+		return p.error("expected '%s', found '%s'", token, p.peek())
+	}
+	p.advance()
+	return nil
+}
+
+// expression parses binary operators of at least minPrecedence, by precedence climbing
+func (p *infixParser) expression(minPrecedence int) error {
+	if err := p.unary(); err != nil {
+		return err
+	}
+
+	for {
+		op := p.peek()
+		precedence, ok := infixPrecedence[op]
+		if !ok || precedence < minPrecedence {
+			return nil
+		}
+		p.advance()
+		if err := p.expression(precedence + 1); err != nil {
+			return err
+		}
+		p.emit(p.defs.operator(op))
+	}
+}
+
+func (p *infixParser) unary() error {
+	switch op := p.peek(); op {
+	case "-", "+", "~":
+		p.advance()
+		if err := p.unary(); err != nil {
+			return err
+		}
+		if op == "-" {
+			p.emit("chs")
+		} else if op == "~" {
+			p.emit(op)
+		}
+		return nil
+	}
+	return p.power()
+}
+
+func (p *infixParser) power() error {
+	if err := p.postfix(); err != nil {
+		return err
+	}
+
+	if p.peek() == "**" {
+		p.advance()
+		if err := p.unary(); err != nil {
+			return err
+		}
+		p.emit("**")
+	}
+	return nil
+}
+
+// postfix parses an operand followed by any factorials and units to apply or convert to
+func (p *infixParser) postfix() error {
+	if err := p.primary(); err != nil {
+		return err
+	}
+
+	for token := p.peek(); token == "!" || token != "" && p.defs.isUnits(token) && !p.defs.isOperand(token); token = p.peek() {
+		p.emit(p.advance())
+	}
+	return nil
+}
+
+func (p *infixParser) primary() error {
+	token := p.peek()
+	switch {
+	case token == "":
+		return p.error("missing operand")
+	case token == "(":
+		p.advance()
+		if err := p.expression(1); err != nil {
+			return err
+		}
+		return p.expect(")")
+	case p.defs.isFunction(token) && p.next+1 < len(p.tokens) && p.tokens[p.next+1] == "(":
+		return p.call()
+	case p.defs.isOperand(token):
+		p.emit(p.advance())
+	case p.defs.isUnits(token):
+		p.emit("1", p.advance())
+	default:
+		return p.error("unexpected '%s'", token)
+	}
+	return nil
+}
+
+// call parses name(arguments), with one argument for unary operators and two for binary
+func (p *infixParser) call() error {
+	name := p.advance()
+	p.advance() // (
+
+	want := 2
+	if OPERATOR[p.defs.operator(name)].unary {
+		want = 1
+	}
+
+	for i := range want {
+		if i > 0 {
+			if err := p.expect(","); err != nil {
+				return err
+			}
+		}
+		if err := p.expression(1); err != nil {
+			return err
+		}
+	}
+	if p.peek() == "," {
+		return p.error("too many arguments for '%s', expected %d", name, want)
+	}
+	if err := p.expect(")"); err != nil {
+		return err
+	}
+
+	p.emit(name)
+	return nil
+}
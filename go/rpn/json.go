@@ -0,0 +1,115 @@
+// Copyright 2024 Mike Carlton
+// Released under terms of the MIT License:
+//   http://www.opensource.org/licenses/mit-license.php
+
+package rpn
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+)
+
+// jsonValue is the structured form of a Value for --json output; numerator and denominator
+// are strings so they stay exact beyond the range of JSON numbers
+type jsonValue struct {
+	Numerator            string         `json:"numerator"`
+	Denominator          string         `json:"denominator"`
+	ImaginaryNumerator   string         `json:"imaginary_numerator,omitempty"`
+	ImaginaryDenominator string         `json:"imaginary_denominator,omitempty"`
+	Decimal              string         `json:"decimal"`
+	Units                string         `json:"units"`
+	Dimensions           map[string]int `json:"dimensions"`
+	Hex                  string         `json:"hex,omitempty"`
+	Octal                string         `json:"octal,omitempty"`
+	Binary               string         `json:"binary,omitempty"`
+	IPv4                 string         `json:"ipv4,omitempty"`
+	Factors              []string       `json:"factors,omitempty"`
+	Composite            []string       `json:"possibly_composite,omitempty"`
+}
+
+type jsonRegister struct {
+	Name string `json:"name"`
+	jsonValue
+}
+
+type jsonStack struct {
+	Stack     []jsonValue    `json:"stack"`
+	Registers []jsonRegister `json:"registers,omitempty"`
+}
+
+// toJSON returns the structured form of v, with the other bases, IPv4 and factors when selected in opts
+func (v Value) toJSON(opts *Options) jsonValue {
+	n := v.number
+	result := jsonValue{
+		Numerator:   n.Rat.Num().String(),
+		Denominator: n.Rat.Denom().String(),
+		Decimal:     n.formatWith(opts),
+		Units:       v.units.Format(opts),
+		Dimensions:  map[string]int{},
+	}
+
+	if n.isComplex() {
+		result.ImaginaryNumerator = n.imag.Num().String()
+		result.ImaginaryDenominator = n.imag.Denom().String()
+	}
+
+	for dimension, unit := range v.units {
+		if unit.power != 0 {
+			result.Dimensions[opts.defs.dimensionName(dimension)] = unit.power
+		}
+	}
+
+	// Other bases only apply to integers, matching the columns of Print
+	if n.isIntegral() {
+		plain := *opts
+		plain.Group = false
+		if opts.ShowHex {
+			result.Hex = toString(n, 16, &plain)
+		}
+		if opts.ShowOctal {
+			result.Octal = toString(n, 8, &plain)
+		}
+		if opts.ShowBinary {
+			result.Binary = toString(n, 2, &plain)
+		}
+	} else if opts.ShowHex && opts.ShowHexFloat && !n.isComplex() {
+		result.Hex = toString(n, 16, opts)
+	}
+
+	if opts.ShowIPv4 {
+		result.IPv4 = toIPv4(n)
+	}
+	if opts.ShowFactor {
+		factors, questionable := primeFactors(n)
+		result.Factors = factors
+		for _, q := range questionable {
+			result.Composite = append(result.Composite, q.String())
+		}
+	}
+
+	return result
+}
+
+// PrintJSON writes the stack, bottom first, and the named registers as a JSON object
+func (s *Stack) PrintJSON(w io.Writer, opts *Options) error {
+	opts = s.options(opts)
+	output := jsonStack{Stack: []jsonValue{}}
+	for _, v := range s.values {
+		output.Stack = append(output.Stack, v.toJSON(opts))
+	}
+
+	names := make([]string, 0, len(s.registers))
+	for name := range s.registers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		output.Registers = append(output.Registers, jsonRegister{Name: name, jsonValue: s.registers[name].toJSON(opts)})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(output)
+}
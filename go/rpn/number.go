@@ -2,7 +2,7 @@
 // Released under terms of the MIT License:
 //   http://www.opensource.org/licenses/mit-license.php
 
-package main
+package rpn
 
 import (
 	"fmt"
@@ -15,31 +15,46 @@ import (
 )
 
 // Embed *big.Rat; all big.Rat methods can be applied directly on Number
+// The embedded Rat is the real part; complex numbers also have an imaginary part
 type Number struct {
 	*big.Rat
+	imag *big.Rat // imaginary part, nil for real numbers
 }
 
-type NumericOp func(*Number, *Number) *Number
+// NumericOp computes an operator's result, to working precision p where it is not an exact rational
+type NumericOp func(x, y *Number, p precision) (*Number, error)
 
-var PrecisionLimit int = 4 // default, overridden by options.precision
-var Pi = newNumber(        // 40 digits ought to be enough
-	"3141592653589793238462643383279502884197/" +
-		"1000000000000000000000000000000000000000")
+// Pi is π to 100 digits, more than any working precision needs, so angles convert between units
+// (Pi/180 rad in a deg) without losing the digits of inexact results
+var Pi = newNumber(
+	"31415926535897932384626433832795028841971693993751" +
+		"058209749445923078164062862089986280348253421170680/1" + strings.Repeat("0", 100))
 
-// stringifies a Number, with only as much precision (up to our configured limit) as is required to display exactly
+// stringifies a Number, with only as much precision (up to the default limit) as is required to display exactly
 func (n *Number) String() string {
+	return n.format(defaultPrecision)
+}
+
+// formats a Number with only as much precision (up to precisionLimit) as is required to display exactly
+func (n *Number) format(precisionLimit int) string {
 	if n.Rat == nil {
 		panic("Uninitialized Number")
 	}
 
-	precisionLimit := options.precision
-	precision, exact := n.Rat.FloatPrec()
+	if n.isComplex() {
+		return n.formatRectangular(precisionLimit)
+	}
+	return formatRat(n.Rat, precisionLimit)
+}
+
+func formatRat(r *big.Rat, precisionLimit int) string {
+	precision, exact := r.FloatPrec()
 	if exact {
 		precision = min(precisionLimit, precision)
 	} else {
 		precision = precisionLimit
 	}
-	return n.Rat.FloatString(precision)
+	return r.FloatString(precision)
 }
 
 func (n *Number) GoString() string { // for %#v format
@@ -131,11 +146,6 @@ func (n *Number) SetString(value string) (*Number, bool) {
 		n.Rat = new(big.Rat)
 	}
 
-	// If number contains comma or underscore separators, enable grouping
-	if strings.Contains(value, ",") || strings.Contains(value, "_") {
-		options.group = true
-	}
-
 	// Remove comma and underscore separators before parsing
 	cleanValue := strings.ReplaceAll(strings.ReplaceAll(value, ",", ""), "_", "")
 	_, ok := n.Rat.SetString(cleanValue)
@@ -147,36 +157,63 @@ func (n *Number) Add(x, y *Number) *Number {
 	if n.Rat == nil {
 		n.Rat = new(big.Rat)
 	}
+	if x.isComplex() || y.isComplex() {
+		imag := new(big.Rat).Add(x.imagPart(), y.imagPart())
+		n.Rat.Add(x.Rat, y.Rat)
+		return n.setImag(imag)
+	}
 	n.Rat.Add(x.Rat, y.Rat)
 
-	return n
+	return n.setImag(nil)
 }
 
 func (n *Number) Sub(x, y *Number) *Number {
 	if n.Rat == nil {
 		n.Rat = new(big.Rat)
 	}
+	if x.isComplex() || y.isComplex() {
+		imag := new(big.Rat).Sub(x.imagPart(), y.imagPart())
+		n.Rat.Sub(x.Rat, y.Rat)
+		return n.setImag(imag)
+	}
 	n.Rat.Sub(x.Rat, y.Rat)
 
-	return n
+	return n.setImag(nil)
 }
 
 func (n *Number) Mul(x, y *Number) *Number {
 	if n.Rat == nil { // Initialize if necessary
 		n.Rat = new(big.Rat)
 	}
+	if x.isComplex() || y.isComplex() {
+		// (a + bi)(c + di) = (ac - bd) + (ad + bc)i
+		a, b, c, d := x.Rat, x.imagPart(), y.Rat, y.imagPart()
+		re := new(big.Rat).Sub(new(big.Rat).Mul(a, c), new(big.Rat).Mul(b, d))
+		imag := new(big.Rat).Add(new(big.Rat).Mul(a, d), new(big.Rat).Mul(b, c))
+		n.Rat.Set(re)
+		return n.setImag(imag)
+	}
 	n.Rat.Mul(x.Rat, y.Rat)
 
-	return n
+	return n.setImag(nil)
 }
 
 func (n *Number) Quo(x, y *Number) *Number {
 	if n.Rat == nil { // Initialize if necessary
 		n.Rat = new(big.Rat)
 	}
+	if x.isComplex() || y.isComplex() {
+		// (a + bi)/(c + di) = ((ac + bd) + (bc - ad)i) / (c² + d²)
+		a, b, c, d := x.Rat, x.imagPart(), y.Rat, y.imagPart()
+		norm := new(big.Rat).Add(new(big.Rat).Mul(c, c), new(big.Rat).Mul(d, d))
+		re := new(big.Rat).Add(new(big.Rat).Mul(a, c), new(big.Rat).Mul(b, d))
+		imag := new(big.Rat).Sub(new(big.Rat).Mul(b, c), new(big.Rat).Mul(a, d))
+		n.Rat.Quo(re, norm)
+		return n.setImag(imag.Quo(imag, norm))
+	}
 	n.Rat.Quo(x.Rat, y.Rat)
 
-	return n
+	return n.setImag(nil)
 }
 
 // Constants
@@ -204,49 +241,56 @@ func div(x, y *Number) *Number {
 	return result.Quo(x, y)
 }
 
-func pow(x, y *Number) *Number {
-	// For now, implement simple integer power
+func pow(x, y *Number, p precision) (*Number, error) {
+	// Integer powers are exact
 	if y.isIntegral() {
 		if !y.Rat.Num().IsInt64() {
-			panic("Integer exponent is too large")
+			return nil, fmt.Errorf("%w: integer exponent is too large", ErrDomain)
 		}
 		exp := y.Rat.Num().Int64()
-		result := newNumber(1)
-		base := newNumber(x.String())
+		base := x
 
 		if exp < 0 {
-			base = reciprocal(base, nil)
+			if base.isZero() {
+				return nil, fmt.Errorf("%w: cannot raise zero to a negative power", ErrDivisionByZero)
+			}
+			base = div(newNumber(1), base)
 			// handle the minimum int64 edge case safely
 			if exp == math.MinInt64 {
-				panic("Exponent value too small to negate")
+				return nil, fmt.Errorf("%w: exponent value too small to negate", ErrDomain)
 			}
 			exp = -exp
 		}
 
-		for i := int64(0); i < exp; i++ {
-			result = mul(result, base)
+		if base.isComplex() {
+			return complexIntPow(base, exp), nil
 		}
-		return result
-	}
-
-	// For non-integer powers, approximate using float64
-	xFloat, _ := x.Rat.Float64()
-	yFloat, _ := y.Rat.Float64()
-
-	if xFloat <= 0 {
-		panic("Cannot raise negative number to non-integer power")
+		power := big.NewInt(exp)
+		num := new(big.Int).Exp(base.Rat.Num(), power, nil)
+		den := new(big.Int).Exp(base.Rat.Denom(), power, nil)
+		return &Number{Rat: new(big.Rat).SetFrac(num, den)}, nil
+	}
+
+	// Non-integer powers are exact for rational roots, otherwise computed to the working precision
+	// Negative and complex bases, and complex exponents, have complex results (the principal value)
+	if x.isComplex() || y.isComplex() || x.Rat.Sign() < 0 {
+		return complexPow(x, y, p)
+	} else if x.Rat.Sign() == 0 {
+		if y.Rat.Sign() < 0 {
+			return nil, fmt.Errorf("%w: cannot raise zero to a negative power", ErrDivisionByZero)
+		}
+		return newNumber(0), nil
 	}
 
-	result := math.Pow(xFloat, yFloat)
-	return newNumber(result)
+	return rationalPow(x, y, p)
 }
 
-func factorial(x, y *Number) *Number {
+func factorial(x, y *Number, p precision) (*Number, error) {
 	if !x.isIntegral() || x.Rat.Sign() < 0 {
-		panic("Factorial is only defined for non-negative integers")
+		return nil, fmt.Errorf("%w: factorial is only defined for non-negative integers", ErrDomain)
 	}
 	if !x.Rat.Num().IsInt64() {
-		panic("Integer exponent is too large")
+		return nil, fmt.Errorf("%w: factorial argument is too large", ErrDomain)
 	}
 
 	result := newNumber(1)
@@ -254,81 +298,359 @@ func factorial(x, y *Number) *Number {
 		result.Mul(result, newNumber(i))
 	}
 
-	return result
+	return result, nil
 }
 
-func neg(x, y *Number) *Number {
+func neg(x, y *Number, p precision) (*Number, error) {
 	result := new(Number)
 	result.Set(0)
-	return result.Sub(result, x)
+	return result.Sub(result, x), nil
 }
 
-func truncate(x, y *Number) *Number {
+func truncate(x, y *Number, p precision) (*Number, error) {
 	result := new(Number)
-	result.Set(x.String())
+	result.Set(0)
 
 	// Extract integer part
 	intPart := new(big.Int)
-	intPart.Quo(result.Rat.Num(), result.Rat.Denom())
+	intPart.Quo(x.Rat.Num(), x.Rat.Denom())
 	result.Rat.SetInt(intPart)
 
-	return result
+	return result, nil
 }
 
-func reciprocal(x, y *Number) *Number {
+func reciprocal(x, y *Number, p precision) (*Number, error) {
+	if x.isZero() {
+		return nil, fmt.Errorf("%w: reciprocal of zero", ErrDivisionByZero)
+	}
 	result := new(Number)
 	one := newNumber(1)
-	return result.Quo(one, x)
+	return result.Quo(one, x), nil
+}
+
+func quotient(x, y *Number, p precision) (*Number, error) {
+	if y.isZero() {
+		return nil, ErrDivisionByZero
+	}
+	return div(x, y), nil
+}
+
+func log(x, y *Number, p precision) (*Number, error) {
+	if x.isZero() {
+		return nil, fmt.Errorf("%w: cannot take log of zero", ErrDomain)
+	} else if x.isComplex() || x.Rat.Sign() < 0 {
+		return complexLog(x, 0, p), nil
+	}
+
+	return logBase(x, 0, p), nil
+}
+
+func log10(x, y *Number, p precision) (*Number, error) {
+	if x.isZero() {
+		return nil, fmt.Errorf("%w: cannot take log of zero", ErrDomain)
+	} else if x.isComplex() || x.Rat.Sign() < 0 {
+		return complexLog(x, 10, p), nil
+	}
+
+	return logBase(x, 10, p), nil
+}
+
+func log2(x, y *Number, p precision) (*Number, error) {
+	if x.isZero() {
+		return nil, fmt.Errorf("%w: cannot take log of zero", ErrDomain)
+	} else if x.isComplex() || x.Rat.Sign() < 0 {
+		return complexLog(x, 2, p), nil
+	}
+
+	return logBase(x, 2, p), nil
+}
+
+func random(x, y *Number, p precision) (*Number, error) {
+	return mul(x, newNumber(rand.Float64())), nil
+}
+
+func sqrt(x, y *Number, p precision) (*Number, error) {
+	if x.isComplex() || x.Rat.Sign() < 0 {
+		return complexSqrt(x, p), nil
+	}
+
+	if root, ok := exactRoot(x, 2); ok {
+		return root, nil
+	}
+
+	prec := p.bits(integerDigits(new(big.Float).SetRat(x.Rat))/2 + 1)
+	result := new(big.Float).SetPrec(prec).SetRat(x.Rat)
+	return p.fromFloat(result.Sqrt(result)), nil
+}
+
+func cbrt(x, y *Number, p precision) (*Number, error) {
+	return root(x, 3, p)
+}
+
+// nroot returns the y-th root of x, for an integer y
+func nroot(x, y *Number, p precision) (*Number, error) {
+	if !y.isIntegral() || !y.Rat.Num().IsInt64() {
+		return nil, fmt.Errorf("%w for the degree of a root, got %v", ErrNotInteger, y)
+	}
+	return root(x, y.Rat.Num().Int64(), p)
+}
+
+// root returns the n-th root of x, which is real and negative for odd roots of negative numbers (cbrt -8 is -2);
+// even roots of negative numbers and roots of complex numbers are the principal value, as for **
+func root(x *Number, n int64, p precision) (*Number, error) {
+	if n == 0 {
+		return nil, fmt.Errorf("%w: no zeroth root", ErrDomain)
+	}
+	if x.Rat.Sign() < 0 && !x.isComplex() && n%2 != 0 {
+		result, err := root(mul(x, newNumber(-1)), n, p)
+		if err != nil {
+			return nil, err
+		}
+		return mul(result, newNumber(-1)), nil
+	}
+	return pow(x, newRationalNumber(1, n), p)
+}
+
+// Trigonometric functions take and inverse functions return radians
+func sin(x, y *Number, p precision) (*Number, error) {
+	if x.Rat.Sign() == 0 {
+		return newNumber(0), nil
+	}
+
+	arg, prec := p.trigArg(x)
+	result, _ := bigSinCos(arg, prec)
+	return p.fromFloat(result), nil
+}
+
+func cos(x, y *Number, p precision) (*Number, error) {
+	arg, prec := p.trigArg(x)
+	_, result := bigSinCos(arg, prec)
+	return p.fromFloat(result), nil
+}
+
+func tan(x, y *Number, p precision) (*Number, error) {
+	if x.Rat.Sign() == 0 {
+		return newNumber(0), nil
+	}
+	if halves, ok := halfPiMultiple(x); ok && halves.Bit(0) == 1 {
+		return nil, fmt.Errorf("%w: tangent is undefined", ErrDomain)
+	} else if ok {
+		return newNumber(0), nil
+	}
+
+	arg, prec := p.trigArg(x)
+	sin, cos := bigSinCos(arg, prec)
+	return p.fromFloat(sin.Quo(sin, cos)), nil
 }
 
-func log(x, y *Number) *Number {
-	xFloat, _ := x.Rat.Float64()
-	if xFloat <= 0 {
-		panic("Cannot take log of non-positive number")
+// halfPiMultiple returns x as a number of π/2, if it is exactly one (e.g. 90 deg is 1, 180 deg is 2)
+func halfPiMultiple(x *Number) (*big.Int, bool) {
+	halves := new(big.Rat).Quo(x.Rat, Pi.Rat)
+	halves.Mul(halves, big.NewRat(2, 1))
+	return halves.Num(), halves.IsInt()
+}
+
+// piMultiple returns the angle num/den·π as an exact multiple of Pi, so it converts exactly to deg (π/2 is 90 deg)
+func piMultiple(num, den int64) *Number {
+	return mul(newRationalNumber(num, den), Pi)
+}
+
+// exactAsin returns asin(x) as a multiple of π if it is one, which for rational x is only 0, ±1/2 and ±1 (Niven's theorem)
+func exactAsin(x *Number) (*Number, bool) {
+	switch sign := int64(x.Rat.Sign()); {
+	case sign == 0:
+		return newNumber(0), true
+	case new(big.Rat).Abs(x.Rat).Cmp(big.NewRat(1, 2)) == 0:
+		return piMultiple(sign, 6), true
+	case new(big.Rat).Abs(x.Rat).Cmp(big.NewRat(1, 1)) == 0:
+		return piMultiple(sign, 2), true
 	}
+	return nil, false
+}
 
-	result := math.Log(xFloat)
-	return newNumber(result)
+// exactAtan2 returns the angle of the rational point (y, x), not the origin, as a multiple of π if it is one,
+// which is only on the axes and the diagonals
+func exactAtan2(y, x *big.Rat) (*Number, bool) {
+	switch ySign, xSign := int64(y.Sign()), int64(x.Sign()); {
+	case ySign == 0 && xSign > 0:
+		return newNumber(0), true
+	case ySign == 0:
+		return piMultiple(1, 1), true
+	case xSign == 0:
+		return piMultiple(ySign, 2), true
+	case new(big.Rat).Abs(y).Cmp(new(big.Rat).Abs(x)) == 0 && xSign > 0:
+		return piMultiple(ySign, 4), true
+	case new(big.Rat).Abs(y).Cmp(new(big.Rat).Abs(x)) == 0:
+		return piMultiple(3*ySign, 4), true
+	}
+	return nil, false
 }
 
-func log10(x, y *Number) *Number {
-	xFloat, _ := x.Rat.Float64()
-	if xFloat <= 0 {
-		panic("Cannot take log of non-positive number")
+func asin(x, y *Number, p precision) (*Number, error) {
+	if new(big.Rat).Abs(x.Rat).Cmp(big.NewRat(1, 1)) > 0 {
+		return nil, fmt.Errorf("%w: asin requires a value in [-1, 1]", ErrDomain)
+	}
+	if result, ok := exactAsin(x); ok {
+		return result, nil
 	}
 
-	result := math.Log10(xFloat)
-	return newNumber(result)
+	prec := p.bits(0)
+	arg := new(big.Float).SetPrec(prec).SetRat(x.Rat)
+	prec += smallBits(arg)
+
+	// asin(x) = atan(x / sqrt(1 - x²)), for |x| < 1
+	root := new(big.Float).SetPrec(prec).Mul(arg, arg)
+	root.Sub(big.NewFloat(1), root)
+	root.Sqrt(root)
+	return p.fromFloat(bigAtan(root.Quo(arg, root), prec)), nil
 }
 
-func log2(x, y *Number) *Number {
-	xFloat, _ := x.Rat.Float64()
-	if xFloat <= 0 {
-		panic("Cannot take log of non-positive number")
+func acos(x, y *Number, p precision) (*Number, error) {
+	if new(big.Rat).Abs(x.Rat).Cmp(big.NewRat(1, 1)) > 0 {
+		return nil, fmt.Errorf("%w: acos requires a value in [-1, 1]", ErrDomain)
+	}
+
+	if result, ok := exactAsin(x); ok {
+		// acos(x) = π/2 - asin(x)
+		return sub(piMultiple(1, 2), result), nil
 	}
 
-	result := math.Log2(xFloat)
-	return newNumber(result)
+	// acos(x) = atan2(sqrt(1 - x²), x)
+	root, err := sqrt(sub(newNumber(1), mul(x, x)), nil, p)
+	if err != nil {
+		return nil, err
+	}
+	return atan2(root, x, p)
 }
 
-func random(x, y *Number) *Number {
-	return x.Mul(x, newNumber(rand.Float64()))
+func atan(x, y *Number, p precision) (*Number, error) {
+	if result, ok := exactAtan2(x.Rat, big.NewRat(1, 1)); ok {
+		return result, nil
+	}
+
+	prec := p.bits(0)
+	arg := new(big.Float).SetPrec(prec).SetRat(x.Rat)
+	return p.fromFloat(bigAtan(arg, prec+smallBits(arg))), nil
 }
 
-func sqrt(x, y *Number) *Number {
-	xFloat, _ := x.Rat.Float64()
-	if xFloat < 0 {
-		panic("Cannot take square root of negative number")
+// atan2 returns the angle of the point (y, x), for y x atan2
+func atan2(y, x *Number, p precision) (*Number, error) {
+	if x.Rat.Sign() == 0 && y.Rat.Sign() == 0 {
+		return nil, fmt.Errorf("%w: atan2 is undefined at the origin", ErrDomain)
+	}
+	if result, ok := exactAtan2(y.Rat, x.Rat); ok {
+		return result, nil
 	}
 
-	result := math.Sqrt(xFloat)
-	return newNumber(result)
+	return p.fromFloat(bigAtan2(y.Rat, x.Rat, p.bits(0))), nil
+}
+
+// Hyperbolic functions, computed from exponentials and logs
+func sinh(x, y *Number, p precision) (*Number, error) {
+	if x.Rat.Sign() == 0 {
+		return newNumber(0), nil
+	}
+	if err := expInRange(x); err != nil {
+		return nil, err
+	}
+
+	// sinh(x) = (eˣ - e⁻ˣ) / 2
+	arg, prec := p.trigArg(x)
+	result := bigExp(arg, prec)
+	result.Sub(result, bigExp(new(big.Float).Neg(arg), prec))
+	return p.fromFloat(result.Quo(result, big.NewFloat(2))), nil
+}
+
+func cosh(x, y *Number, p precision) (*Number, error) {
+	if err := expInRange(x); err != nil {
+		return nil, err
+	}
+
+	// cosh(x) = (eˣ + e⁻ˣ) / 2
+	arg, prec := p.trigArg(x)
+	result := bigExp(arg, prec)
+	result.Add(result, bigExp(new(big.Float).Neg(arg), prec))
+	return p.fromFloat(result.Quo(result, big.NewFloat(2))), nil
+}
+
+func tanh(x, y *Number, p precision) (*Number, error) {
+	if x.Rat.Sign() == 0 {
+		return newNumber(0), nil
+	}
+	// Once e⁻²ˣ is below the working precision, tanh(x) is ±1
+	if magnitude, _ := new(big.Rat).Abs(x.Rat).Float64(); 2*magnitude*math.Log2E > float64(p.bits(0)) {
+		return newNumber(int64(x.Rat.Sign())), nil
+	}
+
+	// tanh(x) = (e²ˣ - 1) / (e²ˣ + 1)
+	arg, prec := p.trigArg(x)
+	exp := bigExp(arg.Mul(arg, big.NewFloat(2)), prec)
+	numerator := new(big.Float).SetPrec(prec).Sub(exp, big.NewFloat(1))
+	return p.fromFloat(numerator.Quo(numerator, exp.Add(exp, big.NewFloat(1)))), nil
+}
+
+// expInRange checks that e**|x|, and so sinh(x) and cosh(x), is within the range of computed results
+func expInRange(x *Number) error {
+	if magnitude, _ := new(big.Rat).Abs(x.Rat).Float64(); magnitude*math.Log10E > maxDecimalExponent {
+		return fmt.Errorf("%w: result out of range", ErrDomain)
+	}
+	return nil
+}
+
+func asinh(x, y *Number, p precision) (*Number, error) {
+	if x.Rat.Sign() == 0 {
+		return newNumber(0), nil
+	}
+
+	// asinh(x) = ln(|x| + sqrt(x² + 1)), with the sign of x
+	arg, prec := p.trigArg(x)
+	arg.Abs(arg)
+	root := new(big.Float).SetPrec(prec).Mul(arg, arg)
+	root.Add(root, big.NewFloat(1))
+	root.Sqrt(root)
+	result := bigLog(root.Add(root, arg), prec)
+	if x.Rat.Sign() < 0 {
+		result.Neg(result)
+	}
+	return p.fromFloat(result), nil
+}
+
+func acosh(x, y *Number, p precision) (*Number, error) {
+	if x.Rat.Cmp(big.NewRat(1, 1)) < 0 {
+		return nil, fmt.Errorf("%w: acosh requires a value of at least 1", ErrDomain)
+	}
+
+	// acosh(x) = ln(x + sqrt(x² - 1))
+	arg, prec := p.trigArg(x)
+	root := new(big.Float).SetPrec(prec).Mul(arg, arg)
+	root.Sub(root, big.NewFloat(1))
+	root.Sqrt(root)
+	return p.fromFloat(bigLog(root.Add(root, arg), prec)), nil
+}
+
+func atanh(x, y *Number, p precision) (*Number, error) {
+	if new(big.Rat).Abs(x.Rat).Cmp(big.NewRat(1, 1)) >= 0 {
+		return nil, fmt.Errorf("%w: atanh requires a value in (-1, 1)", ErrDomain)
+	}
+	if x.Rat.Sign() == 0 {
+		return newNumber(0), nil
+	}
+
+	// atanh(x) = ln((1 + x) / (1 - x)) / 2
+	arg, prec := p.trigArg(x)
+	if arg.Cmp(big.NewFloat(-0.5)) > 0 && arg.Cmp(big.NewFloat(0.5)) < 0 {
+		return p.fromFloat(atanhSeries(arg.SetPrec(prec))), nil
+	}
+	ratio := new(big.Float).SetPrec(prec).Add(big.NewFloat(1), arg)
+	ratio.Quo(ratio, new(big.Float).SetPrec(prec).Sub(big.NewFloat(1), arg))
+	result := bigLog(ratio, prec)
+	return p.fromFloat(result.Quo(result, big.NewFloat(2))), nil
 }
 
 // Bitwise operations - only work on integral numbers
-func bitwiseAnd(x, y *Number) *Number {
+func bitwiseAnd(x, y *Number, p precision) (*Number, error) {
 	if !x.isIntegral() || !y.isIntegral() {
-		panic("Bitwise operations require integral values")
+		return nil, fmt.Errorf("%w: bitwise operations require integral values", ErrNotInteger)
 	}
 
 	xInt := new(big.Int)
@@ -339,12 +661,12 @@ func bitwiseAnd(x, y *Number) *Number {
 	result := new(big.Int)
 	result.And(xInt, yInt)
 
-	return newNumber(result.String())
+	return newNumber(result.String()), nil
 }
 
-func bitwiseOr(x, y *Number) *Number {
+func bitwiseOr(x, y *Number, p precision) (*Number, error) {
 	if !x.isIntegral() || !y.isIntegral() {
-		panic("Bitwise operations require integral values")
+		return nil, fmt.Errorf("%w: bitwise operations require integral values", ErrNotInteger)
 	}
 
 	xInt := new(big.Int)
@@ -355,12 +677,12 @@ func bitwiseOr(x, y *Number) *Number {
 	result := new(big.Int)
 	result.Or(xInt, yInt)
 
-	return newNumber(result.String())
+	return newNumber(result.String()), nil
 }
 
-func bitwiseXor(x, y *Number) *Number {
+func bitwiseXor(x, y *Number, p precision) (*Number, error) {
 	if !x.isIntegral() || !y.isIntegral() {
-		panic("Bitwise operations require integral values")
+		return nil, fmt.Errorf("%w: bitwise operations require integral values", ErrNotInteger)
 	}
 
 	xInt := new(big.Int)
@@ -371,12 +693,12 @@ func bitwiseXor(x, y *Number) *Number {
 	result := new(big.Int)
 	result.Xor(xInt, yInt)
 
-	return newNumber(result.String())
+	return newNumber(result.String()), nil
 }
 
-func leftShift(x, y *Number) *Number {
+func leftShift(x, y *Number, p precision) (*Number, error) {
 	if !x.isIntegral() || !y.isIntegral() {
-		panic("Shift operations require integral values")
+		return nil, fmt.Errorf("%w: shift operations require integral values", ErrNotInteger)
 	}
 
 	xInt := new(big.Int)
@@ -385,19 +707,19 @@ func leftShift(x, y *Number) *Number {
 	yInt.Quo(y.Rat.Num(), y.Rat.Denom())
 
 	if !yInt.IsUint64() {
-		panic("Shift amount must be a valid unsigned integer")
+		return nil, fmt.Errorf("%w: shift amount must be a valid unsigned integer", ErrDomain)
 	}
 
 	shift := yInt.Uint64()
 	result := new(big.Int)
 	result.Lsh(xInt, uint(shift))
 
-	return newNumber(result.String())
+	return newNumber(result.String()), nil
 }
 
-func rightShift(x, y *Number) *Number {
+func rightShift(x, y *Number, p precision) (*Number, error) {
 	if !x.isIntegral() || !y.isIntegral() {
-		panic("Shift operations require integral values")
+		return nil, fmt.Errorf("%w: shift operations require integral values", ErrNotInteger)
 	}
 
 	xInt := new(big.Int)
@@ -406,19 +728,19 @@ func rightShift(x, y *Number) *Number {
 	yInt.Quo(y.Rat.Num(), y.Rat.Denom())
 
 	if !yInt.IsUint64() {
-		panic("Shift amount must be a valid unsigned integer")
+		return nil, fmt.Errorf("%w: shift amount must be a valid unsigned integer", ErrDomain)
 	}
 
 	shift := yInt.Uint64()
 	result := new(big.Int)
 	result.Rsh(xInt, uint(shift))
 
-	return newNumber(result.String())
+	return newNumber(result.String()), nil
 }
 
-func bitwiseNot(x, y *Number) *Number {
+func bitwiseNot(x, y *Number, p precision) (*Number, error) {
 	if !x.isIntegral() {
-		panic("Bitwise operations require integral values")
+		return nil, fmt.Errorf("%w: bitwise operations require integral values", ErrNotInteger)
 	}
 
 	xInt := new(big.Int)
@@ -431,14 +753,14 @@ func bitwiseNot(x, y *Number) *Number {
 	result := new(big.Int)
 	result.Xor(xInt, mask)
 
-	return newNumber(result.String())
+	return newNumber(result.String()), nil
 }
 
 // mask generates an IP mask with the specified number of bits
 // e.g., mask(8) = 0xff000000, mask(24) = 0xffffff00
-func mask(x, y *Number) *Number {
+func mask(x, y *Number, p precision) (*Number, error) {
 	if !x.isIntegral() {
-		panic("Mask operation requires integral value")
+		return nil, fmt.Errorf("%w: mask operation requires integral value", ErrNotInteger)
 	}
 
 	bits := new(big.Int)
@@ -446,17 +768,17 @@ func mask(x, y *Number) *Number {
 
 	// Check if bits is in valid range (0-32)
 	if bits.Sign() < 0 || bits.Cmp(big.NewInt(32)) > 0 {
-		panic("Mask bits must be between 0 and 32")
+		return nil, fmt.Errorf("%w: mask bits must be between 0 and 32", ErrDomain)
 	}
 
 	// Create mask: shift left (32-bits) positions, then invert and shift left bits positions
 	bitsInt := bits.Int64()
 
 	if bitsInt == 0 {
-		return newNumber(0)
+		return newNumber(0), nil
 	}
 	if bitsInt == 32 {
-		return newNumber("4294967295") // 0xffffffff
+		return newNumber("4294967295"), nil // 0xffffffff
 	}
 
 	// Create mask by shifting 1s to the left
@@ -466,12 +788,12 @@ func mask(x, y *Number) *Number {
 	result.Lsh(result, uint(32-bitsInt))
 	result.And(result, big.NewInt(0xffffffff))
 
-	return newNumber(result.String())
+	return newNumber(result.String()), nil
 }
 
-func mod(x, y *Number) *Number {
+func mod(x, y *Number, p precision) (*Number, error) {
 	if y.Rat.Sign() == 0 {
-		panic("Division by zero in modulo operation")
+		return nil, fmt.Errorf("%w in modulo operation", ErrDivisionByZero)
 	}
 
 	// For rational numbers, compute x - y * floor(x/y)
@@ -502,7 +824,7 @@ func mod(x, y *Number) *Number {
 	result.Rat = new(big.Rat)
 	result.Rat.Sub(x.Rat, product)
 
-	return result
+	return result, nil
 }
 
 // newRationalNumber creates a Number from two int64 values (numerator/denominator)
@@ -513,7 +835,7 @@ func newRationalNumber(numerator, denominator int64) *Number {
 
 // isIntegral returns true if the number represents an integer (denominator is 1)
 func (n *Number) isIntegral() bool {
-	return n.Rat.IsInt()
+	return n.Rat.IsInt() && !n.isComplex()
 }
 
 // addCommaGrouping adds comma grouping to a decimal number string
@@ -683,8 +1005,23 @@ var smallPrimes = []int64{
 
 // toFactor converts an integer to prime factorization format (e.g., "2 * 3^2 * 5")
 func toFactor(n *Number) string {
+	parts, questionable := primeFactors(n)
+	factorization := strings.Join(parts, " • ")
+	if len(questionable) > 0 {
+		factorization += Red("\nWarning, may be composite: ")
+		for _, q := range questionable {
+			factorization += fmt.Sprintf(" %s", q.String())
+		}
+	}
+
+	return factorization
+}
+
+// primeFactors returns the prime factors of an integer as "p" or "p^k", ascending (with -1 first if negative),
+// and any factors that may be composite; nil for non-integers, 0 and ±1
+func primeFactors(n *Number) ([]string, []*big.Int) {
 	if !n.isIntegral() {
-		return ""
+		return nil, nil
 	}
 
 	xInt := new(big.Int)
@@ -693,7 +1030,7 @@ func toFactor(n *Number) string {
 	bigOne := big.NewInt(1)
 
 	if xInt.Sign() == 0 || absX.Cmp(bigOne) == 0 {
-		return ""
+		return nil, nil
 	}
 
 	factorMap := make(map[string]int)
@@ -774,15 +1111,7 @@ func toFactor(n *Number) string {
 		}
 	}
 
-	factorization := strings.Join(parts, " • ")
-	if len(questionable) > 0 {
-		factorization += red("\nWarning, may be composite: ")
-		for _, q := range questionable {
-			factorization += fmt.Sprintf(" %s", q.String())
-		}
-	}
-
-	return factorization
+	return parts, questionable
 }
 
 // toIPv4 converts an integer to IPv4 address format (e.g., "192.168.1.1")
@@ -819,10 +1148,10 @@ func toIPv4(n *Number) string {
 	return fmt.Sprintf("%d.%d.%d.%d", octets[0], octets[1], octets[2], octets[3])
 }
 
-func toString(n *Number, base int) string {
+func toString(n *Number, base int, opts *Options) string {
 	if base == 10 {
-		str := n.String()
-		if options.group {
+		str := n.formatWith(opts)
+		if opts.Group {
 			return addCommaGrouping(str, ",")
 		}
 		return str
@@ -845,23 +1174,23 @@ func toString(n *Number, base int) string {
 			}
 
 			// Add underscore grouping if -g option is enabled
-			if options.group {
+			if opts.Group {
 				result = addUnderscoreGrouping(result)
 			}
 			return result
-		} else if options.showHexFloat {
+		} else if opts.ShowHexFloat && !n.isComplex() {
 			// Convert to float64 and format as hex floating point
 			floatVal, _ := n.Rat.Float64()
 			return strconv.FormatFloat(floatVal, 'x', -1, 64)
 		} else {
 			// Return decimal representation for non-integral numbers when hex float not enabled
-			return n.String()
+			return n.format(opts.Precision)
 		}
 	}
 
 	// For binary and octal, we need the number to be integral
 	if !n.isIntegral() {
-		return n.String() // Return decimal representation for non-integral numbers
+		return n.format(opts.Precision) // Return decimal representation for non-integral numbers
 	}
 
 	// Convert to integer for base conversion
@@ -889,7 +1218,7 @@ func toString(n *Number, base int) string {
 	}
 
 	// Add underscore grouping if -g option is enabled for binary and octal
-	if options.group && (base == 2 || base == 8) {
+	if opts.Group && (base == 2 || base == 8) {
 		result = addUnderscoreGrouping(result)
 	}
 
@@ -904,7 +1233,7 @@ func intPow(base *Number, exp int) *Number {
 		}
 	} else if exp < 0 {
 		// Handle negative exponents by calculating the reciprocal
-		baseReciprocal := reciprocal(base, nil)
+		baseReciprocal := div(newNumber(1), base)
 		for i := 0; i < -exp; i++ {
 			result = mul(result, baseReciprocal)
 		}
@@ -1012,28 +1341,28 @@ func parseBase60(input string) (*Number, bool) {
 }
 
 // toIEEE32 formats a number as IEEE 754 single-precision: sign|exponent|mantissa
-// Uses hex digits if -x/-X is set, otherwise binary
-func toIEEE32(n *Number) string {
+// Uses binary digits if binary is set, otherwise hex
+func toIEEE32(n *Number, binary bool) string {
 	f64, _ := n.Rat.Float64()
 	bits := math.Float32bits(float32(f64))
 	sign := (bits >> 31) & 0x1
 	exp := (bits >> 23) & 0xFF
 	mantissa := bits & 0x7FFFFF
-	if options.showBinary {
+	if binary {
 		return fmt.Sprintf("%b|%08b|%023b", sign, exp, mantissa)
 	}
 	return fmt.Sprintf("%x|%02x|%06x", sign, exp, mantissa)
 }
 
 // toIEEE64 formats a number as IEEE 754 double-precision: sign|exponent|mantissa
-// Uses binary if -b is set, otherwise hex
-func toIEEE64(n *Number) string {
+// Uses binary digits if binary is set, otherwise hex
+func toIEEE64(n *Number, binary bool) string {
 	f64, _ := n.Rat.Float64()
 	bits := math.Float64bits(f64)
 	sign := (bits >> 63) & 0x1
 	exp := (bits >> 52) & 0x7FF
 	mantissa := bits & 0x000FFFFFFFFFFFFF
-	if options.showBinary {
+	if binary {
 		return fmt.Sprintf("%b|%011b|%052b", sign, exp, mantissa)
 	}
 	return fmt.Sprintf("%x|%03x|%013x", sign, exp, mantissa)
@@ -1,6 +1,8 @@
-package main
+package rpn
 
 import (
+	"errors"
+	"math/big"
 	"testing"
 )
 
@@ -317,11 +319,20 @@ func TestTemperatureAddition(t *testing.T) {
 		{"dC + dF", "10", "dC", "18", "dF", "+", "20 °CΔ", false}, // 18°FΔ = 10°CΔ
 		{"dF + dC", "18", "dF", "10", "dC", "+", "36 °FΔ", false}, // 10°CΔ = 18°FΔ
 
+		// Absolute scales
+		{"K + K", "300", "K", "10", "K", "+", "310 K", false},
+		{"K + dC", "300", "K", "10", "dC", "+", "310 K", false},
+		{"K + dF", "300", "K", "9", "dF", "+", "305 K", false},
+		{"R + K", "100", "°R", "5", "K", "+", "109 °R", false},
+		{"K + mK", "1", "K", "500", "mK", "+", "1.5 K", false},
+
 		// Invalid cases - different absolute units
 		{"C + F invalid", "20", "C", "68", "F", "+", "", true},
 		{"F + C invalid", "68", "F", "20", "C", "+", "", true},
 		{"C - F invalid", "30", "C", "68", "F", "-", "", true},
 		{"F - C invalid", "86", "F", "20", "C", "-", "", true},
+		{"C + K invalid", "20", "C", "5", "K", "+", "", true},
+		{"K - F invalid", "300", "K", "20", "F", "-", "", true},
 	}
 
 	for _, test := range tests {
@@ -336,19 +347,17 @@ func TestTemperatureAddition(t *testing.T) {
 				units:  createSingleUnit(test.rightUnit),
 			}
 
+			result, err := leftVal.binaryOp(test.op, rightVal, workingPrecision(defaultPrecision))
 			if test.shouldFail {
-				// Test should panic/fail
-				defer func() {
-					if r := recover(); r == nil {
-						t.Errorf("Expected %s %s %s to fail, but it succeeded", test.left+test.leftUnit, test.op, test.right+test.rightUnit)
-					}
-				}()
-
-				_ = leftVal.binaryOp(test.op, rightVal)
+				// Test should fail
+				if !errors.Is(err, ErrIncompatibleUnits) {
+					t.Errorf("Expected %s %s %s to fail with incompatible units, got %v", test.left+test.leftUnit, test.op, test.right+test.rightUnit, err)
+				}
 			} else {
 				// Test should succeed
-				result := leftVal.binaryOp(test.op, rightVal)
-				if result.String() != test.expected {
+				if err != nil {
+					t.Errorf("%s%s %s %s%s failed: %v", test.left, test.leftUnit, test.op, test.right, test.rightUnit, err)
+				} else if result.String() != test.expected {
 					t.Errorf("%s%s %s %s%s = %s, want %s",
 						test.left, test.leftUnit, test.op, test.right, test.rightUnit,
 						result.String(), test.expected)
@@ -379,6 +388,16 @@ func TestTemperatureConversion(t *testing.T) {
 		{"10dC to dF", "10", "dC", "dF", "18 °FΔ"},
 		{"5dC to dC", "5", "dC", "dC", "5 °CΔ"}, // Same units
 		{"9dF to dF", "9", "dF", "dF", "9 °FΔ"}, // Same units
+
+		// Absolute scales
+		{"0C to K", "0", "C", "K", "273.15 K"},
+		{"300K to C", "300", "K", "C", "26.85 °C"},
+		{"32F to K", "32", "F", "K", "273.15 K"},
+		{"491.67R to F", "491.67", "°R", "F", "32 °F"},
+		{"273.15K to R", "273.15", "K", "°R", "491.67 °R"},
+		{"1500mK to K", "1500", "mK", "K", "1.5 K"},
+		{"9dR to K", "9", "dR", "K", "5 K"},
+		{"5K to dF", "5", "K", "dF", "9 °FΔ"},
 	}
 
 	for _, test := range tests {
@@ -389,9 +408,11 @@ func TestTemperatureConversion(t *testing.T) {
 			}
 
 			targetUnit := createSingleUnit(test.toUnit)
-			result := val.apply(targetUnit)
+			result, err := val.apply(targetUnit)
 
-			if result.String() != test.expected {
+			if err != nil {
+				t.Errorf("%s %s to %s failed: %v", test.value, test.fromUnit, test.toUnit, err)
+			} else if result.String() != test.expected {
 				t.Errorf("%s %s to %s = %s, want %s",
 					test.value, test.fromUnit, test.toUnit, result.String(), test.expected)
 			}
@@ -401,9 +422,9 @@ func TestTemperatureConversion(t *testing.T) {
 
 // Helper function to create a Unit array with a single temperature unit
 func createSingleUnit(unitName string) Unit {
-	var units Unit
+	units := Unit{}
 	if unitUnit, exists := UNITS[unitName]; exists {
-		// Copy the Unit array from UNITS table
+		// Copy the Unit from UNITS table
 		for dim, unit := range unitUnit {
 			if unit.power != 0 {
 				units[dim] = unit
@@ -418,118 +439,146 @@ func TestTemperatureEdgeCases(t *testing.T) {
 	tests := []struct {
 		name        string
 		description string
-		operation   func() interface{}
-		shouldPanic bool
+		operation   func() (Value, error)
+		shouldFail  bool
 		expectValue string
 	}{
 		{
 			name:        "Zero absolute addition",
 			description: "0°C + 0°C should equal 0°C",
-			operation: func() interface{} {
+			operation: func() (Value, error) {
 				left := Value{number: newNumber("0"), units: createSingleUnit("C")}
 				right := Value{number: newNumber("0"), units: createSingleUnit("C")}
-				return left.binaryOp("+", right)
+				return left.binaryOp("+", right, workingPrecision(defaultPrecision))
 			},
-			shouldPanic: false,
+			shouldFail:  false,
 			expectValue: "0 °C",
 		},
 		{
 			name:        "Negative delta addition",
 			description: "20°C + (-10°CΔ) should equal 10°C",
-			operation: func() interface{} {
+			operation: func() (Value, error) {
 				left := Value{number: newNumber("20"), units: createSingleUnit("C")}
 				right := Value{number: newNumber("-10"), units: createSingleUnit("dC")}
-				return left.binaryOp("+", right)
+				return left.binaryOp("+", right, workingPrecision(defaultPrecision))
 			},
-			shouldPanic: false,
+			shouldFail:  false,
 			expectValue: "10 °C",
 		},
 		{
 			name:        "Large temperature delta",
 			description: "0°C + 100°CΔ should equal 100°C",
-			operation: func() interface{} {
+			operation: func() (Value, error) {
 				left := Value{number: newNumber("0"), units: createSingleUnit("C")}
 				right := Value{number: newNumber("100"), units: createSingleUnit("dC")}
-				return left.binaryOp("+", right)
+				return left.binaryOp("+", right, workingPrecision(defaultPrecision))
 			},
-			shouldPanic: false,
+			shouldFail:  false,
 			expectValue: "100 °C",
 		},
 		{
 			name:        "Temperature multiplication not allowed",
 			description: "Temperature * Temperature should be invalid",
-			operation: func() interface{} {
+			operation: func() (Value, error) {
 				left := Value{number: newNumber("20"), units: createSingleUnit("C")}
 				right := Value{number: newNumber("68"), units: createSingleUnit("F")}
-				return left.binaryOp("*", right)
+				return left.binaryOp("*", right, workingPrecision(defaultPrecision))
 			},
-			shouldPanic: true,
+			shouldFail:  true,
 			expectValue: "",
 		},
 		{
 			name:        "Same temperature multiplication not allowed",
 			description: "°C * °C should be invalid",
-			operation: func() interface{} {
+			operation: func() (Value, error) {
 				left := Value{number: newNumber("20"), units: createSingleUnit("C")}
 				right := Value{number: newNumber("30"), units: createSingleUnit("C")}
-				return left.binaryOp("*", right)
+				return left.binaryOp("*", right, workingPrecision(defaultPrecision))
 			},
-			shouldPanic: true,
+			shouldFail:  true,
 			expectValue: "",
 		},
 		{
 			name:        "Scalar multiplication allowed",
 			description: "2 * 20°C should equal 40°C",
-			operation: func() interface{} {
+			operation: func() (Value, error) {
 				left := Value{number: newNumber("2"), units: Unit{}}
 				right := Value{number: newNumber("20"), units: createSingleUnit("C")}
-				return left.binaryOp("*", right)
+				return left.binaryOp("*", right, workingPrecision(defaultPrecision))
 			},
-			shouldPanic: false,
+			shouldFail:  false,
 			expectValue: "40 °C",
 		},
 		{
 			name:        "Temperature scalar multiplication allowed",
 			description: "20°C * 2 should equal 40°C",
-			operation: func() interface{} {
+			operation: func() (Value, error) {
 				left := Value{number: newNumber("20"), units: createSingleUnit("C")}
 				right := Value{number: newNumber("2"), units: Unit{}}
-				return left.binaryOp("*", right)
+				return left.binaryOp("*", right, workingPrecision(defaultPrecision))
 			},
-			shouldPanic: false,
+			shouldFail:  false,
 			expectValue: "40 °C",
 		},
 		{
 			name:        "Division allows different absolute units",
 			description: "Temperature division should work regardless of units",
-			operation: func() interface{} {
+			operation: func() (Value, error) {
 				left := Value{number: newNumber("100"), units: createSingleUnit("C")}
 				right := Value{number: newNumber("50"), units: createSingleUnit("F")}
-				return left.binaryOp("/", right)
+				return left.binaryOp("/", right, workingPrecision(defaultPrecision))
 			},
-			shouldPanic: false,
+			shouldFail:  false,
 			expectValue: "2",
 		},
+		{
+			name:        "Absolute scale multiplication allowed",
+			description: "2 K * 3 K should equal 6 K²",
+			operation: func() (Value, error) {
+				left := Value{number: newNumber("2"), units: createSingleUnit("K")}
+				right := Value{number: newNumber("3"), units: createSingleUnit("K")}
+				return left.binaryOp("*", right, workingPrecision(defaultPrecision))
+			},
+			shouldFail:  false,
+			expectValue: "6 K²",
+		},
+		{
+			name:        "Compound temperature units cancel",
+			description: "10 A/K * 5 dC should equal 50 A",
+			operation: func() (Value, error) {
+				units, _ := builtInDefinitions.parseUnits("A/K")
+				left := Value{number: newNumber("10"), units: units}
+				right := Value{number: newNumber("5"), units: createSingleUnit("dC")}
+				return left.binaryOp("*", right, workingPrecision(defaultPrecision))
+			},
+			shouldFail:  false,
+			expectValue: "50 A",
+		},
+		{
+			name:        "Temperature reading times compound unit not allowed",
+			description: "10 A/K * 20°C should be invalid",
+			operation: func() (Value, error) {
+				units, _ := builtInDefinitions.parseUnits("A/K")
+				left := Value{number: newNumber("10"), units: units}
+				right := Value{number: newNumber("20"), units: createSingleUnit("C")}
+				return left.binaryOp("*", right, workingPrecision(defaultPrecision))
+			},
+			shouldFail:  true,
+			expectValue: "",
+		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			if test.shouldPanic {
-				defer func() {
-					if r := recover(); r == nil {
-						t.Errorf("Expected operation to panic, but it succeeded")
-					}
-				}()
-				test.operation()
-			} else {
-				result := test.operation()
-				if val, ok := result.(Value); ok {
-					if val.String() != test.expectValue {
-						t.Errorf("%s: got %s, want %s", test.description, val.String(), test.expectValue)
-					}
-				} else {
-					t.Errorf("Expected Value result, got %T", result)
+			val, err := test.operation()
+			if test.shouldFail {
+				if err == nil {
+					t.Errorf("Expected operation to fail, but it succeeded")
 				}
+			} else if err != nil {
+				t.Errorf("%s: unexpected error %v", test.description, err)
+			} else if val.String() != test.expectValue {
+				t.Errorf("%s: got %s, want %s", test.description, val.String(), test.expectValue)
 			}
 		})
 	}
@@ -570,7 +619,8 @@ func TestNegativeNumberFormatting(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			num := newNumber(test.number)
-			result := toString(num, test.base)
+			opts := DefaultOptions()
+			result := toString(num, test.base, &opts)
 
 			if result != test.expected {
 				t.Errorf("toString(%s, %d) = %s, want %s", test.number, test.base, result, test.expected)
@@ -596,7 +646,8 @@ func TestNegativeNumberFormattingFractional(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			num := newNumber(test.number)
-			result := toString(num, test.base)
+			opts := DefaultOptions()
+			result := toString(num, test.base, &opts)
 
 			if result != test.expected {
 				t.Errorf("toString(%s, %d) = %s, want %s", test.number, test.base, result, test.expected)
@@ -604,3 +655,197 @@ func TestNegativeNumberFormattingFractional(t *testing.T) {
 		})
 	}
 }
+
+// Test that roots, logs and powers are exact where possible and otherwise correct to the working precision
+func TestTranscendentalPrecision(t *testing.T) {
+	tests := []struct {
+		name     string
+		op       NumericOp
+		x        string
+		y        string
+		expected string
+	}{
+		{"sqrt 2", sqrt, "2", "0", "1.41421356237309504880168872421"},
+		{"sqrt 9/4", sqrt, "9/4", "0", "1.5"},
+		{"log 10", log, "10", "0", "2.302585092994045684017991454684"},
+		{"log 1", log, "1", "0", "0"},
+		{"log10 1000", log10, "1000", "0", "3"},
+		{"log10 1/100", log10, "1/100", "0", "-2"},
+		{"log10 2", log10, "2", "0", "0.301029995663981195213738894724"},
+		{"log2 1024", log2, "1024", "0", "10"},
+		{"log2 3", log2, "3", "0", "1.584962500721156181453738943948"},
+		{"8 ** 2/3", pow, "8", "2/3", "4"},
+		{"2 ** 1/2", pow, "2", "1/2", "1.41421356237309504880168872421"},
+		{"2 ** -1/2", pow, "2", "-1/2", "0.707106781186547524400844362105"},
+		{"10 ** 0.3", pow, "10", "0.3", "1.99526231496887960135245539674"},
+		{"0 ** 1/2", pow, "0", "1/2", "0"},
+		{"cbrt 27/8", cbrt, "27/8", "0", "1.5"},
+		{"cbrt -2", cbrt, "-2", "0", "-1.259921049894873164767210607278"},
+		{"5 nroot 32", nroot, "32", "5", "2"},
+		{"-2 nroot 1/4", nroot, "1/4", "-2", "2"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := test.op(newNumber(test.x), newNumber(test.y), 30)
+			if err != nil {
+				t.Fatalf("%s error = %v", test.name, err)
+			}
+			if result.format(30) != test.expected {
+				t.Errorf("%s = %s, want %s", test.name, result.format(30), test.expected)
+			}
+		})
+	}
+}
+
+// Test trigonometric and hyperbolic functions, in radians
+func TestTrigonometric(t *testing.T) {
+	tests := []struct {
+		name     string
+		op       NumericOp
+		x        string
+		y        string
+		expected string
+	}{
+		{"sin 0", sin, "0", "0", "0"},
+		{"sin 1", sin, "1", "0", "0.8414709848"},
+		{"sin 1e6", sin, "1000000", "0", "-0.3499935022"},
+		{"cos 0", cos, "0", "0", "1"},
+		{"cos -2", cos, "-2", "0", "-0.4161468365"},
+		{"tan 1", tan, "1", "0", "1.5574077247"},
+		{"asin 1", asin, "1", "0", "1.5707963268"},
+		{"asin -1/2", asin, "-1/2", "0", "-0.5235987756"},
+		{"acos 1", acos, "1", "0", "0"},
+		{"acos -1", acos, "-1", "0", "3.1415926536"},
+		{"atan 1", atan, "1", "0", "0.7853981634"},
+		{"atan -10", atan, "-10", "0", "-1.4711276743"},
+		{"atan2 1 -1", atan2, "1", "-1", "2.3561944902"},
+		{"atan2 -1 0", atan2, "-1", "0", "-1.5707963268"},
+		{"sinh 1", sinh, "1", "0", "1.1752011936"},
+		{"cosh 1", cosh, "1", "0", "1.5430806348"},
+		{"tanh -1/2", tanh, "-1/2", "0", "-0.4621171573"},
+		{"tanh 1e20", tanh, "1e20", "0", "1"},
+		{"tanh -1e9", tanh, "-1e9", "0", "-1"},
+		{"asinh -1", asinh, "-1", "0", "-0.8813735870"},
+		{"acosh 2", acosh, "2", "0", "1.3169578969"},
+		{"atanh 9/10", atanh, "9/10", "0", "1.4722194896"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := test.op(newNumber(test.x), newNumber(test.y), workingPrecision(defaultPrecision))
+			if err != nil {
+				t.Fatalf("%s error = %v", test.name, err)
+			}
+			if result.format(10) != test.expected {
+				t.Errorf("%s = %s, want %s", test.name, result.format(10), test.expected)
+			}
+		})
+	}
+
+	domainErrors := []struct {
+		op NumericOp
+		x  string
+	}{
+		{asin, "2"}, {acos, "-2"}, {acosh, "1/2"}, {atanh, "1"}, {atan2, "0"},
+		{sinh, "1e7"}, {sinh, "1e9"}, {cosh, "1e20"}, {cosh, "-1e20"},
+	}
+	for _, test := range domainErrors {
+		if _, err := test.op(newNumber(test.x), newNumber(0), workingPrecision(defaultPrecision)); !errors.Is(err, ErrDomain) {
+			t.Errorf("domain error expected for argument %s, got %v", test.x, err)
+		}
+	}
+}
+
+// Test exact complex arithmetic and the complex results of roots, logs and powers
+func TestComplex(t *testing.T) {
+	tests := []struct {
+		name     string
+		op       NumericOp
+		x        string
+		y        string
+		expected string
+	}{
+		{"add", exact(add), "1+2i", "3-2i", "4"},
+		{"sub", exact(sub), "1+2i", "1/2", "0.5+2i"},
+		{"mul", exact(mul), "1+2i", "3-4i", "11+2i"},
+		{"i squared", exact(mul), "i", "i", "-1"},
+		{"div", quotient, "1+2i", "3-4i", "-0.2+0.4i"},
+		{"reciprocal", reciprocal, "2i", "0", "-0.5i"},
+		{"integer pow", pow, "1+i", "8", "16"},
+		{"negative pow", pow, "1+i", "-2", "-0.5i"},
+		{"sqrt negative", sqrt, "-4", "0", "2i"},
+		{"sqrt complex", sqrt, "3+4i", "0", "2+1i"},
+		{"sqrt -i", sqrt, "-2i", "0", "1-1i"},
+		{"log negative", log, "-1", "0", "3.1415926536i"},
+		{"log10 negative", log10, "-1000", "0", "3+1.3643763538i"},
+		{"real to complex power", pow, "2", "i", "0.7692389014+0.6389612763i"},
+		{"i to the i", pow, "i", "i", "0.2078795764"},
+		{"cube root of -8", pow, "-8", "1/3", "1+1.7320508076i"},
+		{"abs", absolute, "3-4i", "0", "5"},
+		{"abs real", absolute, "-3", "0", "3"},
+		{"arg", argument, "-1-1i", "0", "-2.3561944902"},
+		{"conj", conjugate, "3-4i", "0", "3+4i"},
+		{"re", realPart, "3-4i", "0", "3"},
+		{"im", imaginaryPart, "3-4i", "0", "-4"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			x, ok := parseComplex(test.x)
+			if !ok {
+				x = newNumber(test.x)
+			}
+			y, ok := parseComplex(test.y)
+			if !ok {
+				y = newNumber(test.y)
+			}
+
+			result, err := test.op(x, y, workingPrecision(defaultPrecision))
+			if err != nil {
+				t.Fatalf("%s error = %v", test.name, err)
+			}
+			if result.format(10) != test.expected {
+				t.Errorf("%s = %s, want %s", test.name, result.format(10), test.expected)
+			}
+		})
+	}
+
+	if _, err := quotient(newNumber(1), newComplex(new(big.Rat), new(big.Rat)), workingPrecision(defaultPrecision)); !errors.Is(err, ErrDivisionByZero) {
+		t.Errorf("division by complex zero: got %v, want %v", err, ErrDivisionByZero)
+	}
+}
+
+// Test parsing of complex number input
+func TestParseComplex(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		valid    bool
+	}{
+		{"3+4i", "3+4i", true},
+		{"3-4j", "3-4i", true},
+		{"-2.5i", "-2.5i", true},
+		{"i", "1i", true},
+		{"-j", "-1i", true},
+		{"1e2+1.5e1i", "100+15i", true},
+		{"1,000-2_000i", "1000-2000i", true},
+		{"3+0i", "3", true},
+		{"mi", "", false},
+		{"4", "", false},
+		{"3+4", "", false},
+		{"3+4ii", "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			result, ok := parseComplex(test.input)
+			if ok != test.valid {
+				t.Fatalf("parseComplex(%q) valid = %v, want %v", test.input, ok, test.valid)
+			}
+			if ok && result.String() != test.expected {
+				t.Errorf("parseComplex(%q) = %s, want %s", test.input, result, test.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,726 @@
+// Copyright 2024 Mike Carlton
+// Released under terms of the MIT License:
+//   http://www.opensource.org/licenses/mit-license.php
+
+package rpn
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+type Stack struct {
+	values    []Value
+	registers map[string]Value
+	precision precision    // working precision of operations, set by the evaluator from its display precision
+	defs      *definitions // units and preferred units of the evaluator, for display
+	grouped   bool         // numbers were entered with digit separators, so display groups digits
+}
+
+func newStack(defs *definitions) *Stack {
+	return &Stack{values: []Value{}, registers: map[string]Value{}, precision: workingPrecision(defaultPrecision), defs: defs}
+}
+
+// options returns a copy of opts that displays values with the definitions of the stack
+func (s *Stack) options(opts *Options) *Options {
+	displayed := *opts
+	displayed.defs = s.defs
+	displayed.Group = opts.Group || s.grouped
+	return &displayed
+}
+
+// STACKALIAS are the built-in names for stack operations; Evaluator.AliasStackOperation adds more
+var STACKALIAS = Aliases{
+	"dup": "d",
+	"pop": "p",
+}
+
+var STACKOP = map[string]func(*Stack) error{
+	"x": func(s *Stack) error { return s.exchange() },
+	"d": func(s *Stack) error { return s.dup() },
+	"p": func(s *Stack) error {
+		if _, err := s.pop(); err != nil {
+			return fmt.Errorf("%w for '%s'", ErrStackUnderflow, "pop")
+		}
+		return nil
+	},
+	"mini":  func(s *Stack) error { return s.min(false) },
+	"mini!": func(s *Stack) error { return s.min(true) },
+	"max":   func(s *Stack) error { return s.max(false) },
+	"max!":  func(s *Stack) error { return s.max(true) },
+	"mean":  func(s *Stack) error { return s.mean(false) },
+	"mean!": func(s *Stack) error { return s.mean(true) },
+	"size":  func(s *Stack) error { s.stackSize(false); return nil },
+	"size!": func(s *Stack) error { s.stackSize(true); return nil },
+}
+
+func (s *Stack) binaryOp(op string) error {
+	if len(s.values) < 2 {
+		return fmt.Errorf("%w for binary operation '%s'", ErrStackUnderflow, op)
+	}
+	right, _ := s.pop()
+	left, _ := s.pop()
+
+	result, err := left.binaryOp(op, right, s.precision)
+	if err != nil {
+		s.push(left)
+		s.push(right)
+		return err
+	}
+	s.push(result)
+	return nil
+}
+
+func (s *Stack) unaryOp(op string) error {
+	value, err := s.pop()
+	if err != nil {
+		return fmt.Errorf("%w for unary operation '%s'", ErrStackUnderflow, op)
+	}
+
+	result, err := value.unaryOp(op, s.precision)
+	if err != nil {
+		s.push(value)
+		return err
+	}
+	s.push(result)
+	return nil
+}
+
+func (s *Stack) apply(units Unit) error {
+	value, err := s.pop()
+	if err != nil {
+		return fmt.Errorf("%w for '%s'", ErrStackUnderflow, units)
+	}
+
+	given := value
+	if size, ok := units.largestSize(); ok && value.units.empty() {
+		given.number = mul(value.number, size)
+	}
+	result, err := given.apply(units)
+	if err != nil {
+		s.push(value)
+		return err
+	}
+	s.push(result)
+	return nil
+}
+
+func (s *Stack) reduce(op string) error {
+	if len(s.values) < 2 {
+		return fmt.Errorf("%w for reduction operation '@%s'", ErrStackUnderflow, op)
+	}
+
+	// Reduce all values on the stack using the given operation
+	// Start with the bottom value and apply the operation left-to-right
+	result := s.values[0]
+	for i := 1; i < len(s.values); i++ {
+		var err error
+		if result, err = result.binaryOp(op, s.values[i], s.precision); err != nil {
+			return err
+		}
+	}
+
+	// Clear the stack and push the result
+	s.values = []Value{result}
+	return nil
+}
+
+func (s *Stack) push(v Value) {
+	s.values = append(s.values, v)
+}
+
+func (s *Stack) pop() (Value, error) {
+	if len(s.values) == 0 {
+		return Value{}, ErrStackUnderflow
+	}
+	v := s.values[len(s.values)-1]
+	s.values = s.values[:len(s.values)-1]
+
+	return v, nil
+}
+
+func (s *Stack) peek() (Value, error) {
+	if len(s.values) == 0 {
+		return Value{}, ErrStackUnderflow
+	}
+
+	return s.values[len(s.values)-1], nil
+}
+
+func (s *Stack) dup() error {
+	if len(s.values) < 1 {
+		return fmt.Errorf("%w for '%s'", ErrStackUnderflow, "duplicate")
+	}
+
+	// TODO: need to copy value, otherwise they're aliased
+	s.values = append(s.values, s.values[len(s.values)-1])
+	return nil
+}
+
+func (s *Stack) exchange() error {
+	if len(s.values) < 2 {
+		return fmt.Errorf("%w for '%s'", ErrStackUnderflow, "exchange")
+	}
+
+	s.values[len(s.values)-1], s.values[len(s.values)-2] = s.values[len(s.values)-2], s.values[len(s.values)-1]
+	return nil
+}
+
+// store pops the top of the stack into register name
+func (s *Stack) store(name string) error {
+	value, err := s.pop()
+	if err != nil {
+		return fmt.Errorf("%w for '>%s'", ErrStackUnderflow, name)
+	}
+
+	s.registers[name] = value
+	return nil
+}
+
+// recall pushes the value of register name
+func (s *Stack) recall(name string) error {
+	value, ok := s.registers[name]
+	if !ok {
+		return fmt.Errorf("%w '%s'", ErrUnknownRegister, name)
+	}
+
+	s.push(value)
+	return nil
+}
+
+// clearRegister removes register name
+func (s *Stack) clearRegister(name string) error {
+	if _, ok := s.registers[name]; !ok {
+		return fmt.Errorf("%w '%s'", ErrUnknownRegister, name)
+	}
+
+	delete(s.registers, name)
+	return nil
+}
+
+// Registers returns a copy of the named registers
+func (s *Stack) Registers() map[string]Value {
+	registers := make(map[string]Value, len(s.registers))
+	for name, value := range s.registers {
+		registers[name] = value
+	}
+	return registers
+}
+
+func (s *Stack) size() int {
+	return len(s.values)
+}
+
+// Values returns the stack contents, bottom first
+func (s *Stack) Values() []Value {
+	return append([]Value(nil), s.values...)
+}
+
+// Oneline stringifies the whole stack on a single line, bottom first
+func (s *Stack) Oneline(opts *Options) string {
+	opts = s.options(opts)
+	var sb strings.Builder
+	separator := ""
+	for i, v := range s.values {
+		sb.WriteString(fmt.Sprintf("%s%s", separator, v.Format(opts)))
+		if i == 0 {
+			separator = " "
+		}
+	}
+	return sb.String()
+}
+
+// ColumnWidths tracks integer and fractional part widths for alignment
+type ColumnWidths struct {
+	integerWidth    int // width of integer part (before decimal point)
+	fractionalWidth int // width of fractional part (including decimal point)
+}
+
+// return max widths for all enabled base columns, separating integer and fractional parts
+func maxWidths(values []Value, opts *Options) map[int]ColumnWidths {
+	widths := make(map[int]ColumnWidths)
+	bases := getEnabledBases(opts)
+
+	for _, base := range bases {
+		maxIntWidth := 0
+		maxFracWidth := 0
+
+		for _, value := range values {
+			// Skip this base if not applicable to this value type
+			if base != 10 && !value.number.isIntegral() {
+				if base != 16 || !opts.ShowHexFloat {
+					continue
+				}
+			}
+
+			str := toString(value.number, base, opts)
+			intPart, fracPart := splitNumber(str)
+
+			// Widths are in runes, as used by fmt padding (polar form has ∠ and °)
+			if utf8.RuneCountInString(intPart) > maxIntWidth {
+				maxIntWidth = utf8.RuneCountInString(intPart)
+			}
+			if utf8.RuneCountInString(fracPart) > maxFracWidth {
+				maxFracWidth = utf8.RuneCountInString(fracPart)
+			}
+		}
+
+		widths[base] = ColumnWidths{
+			integerWidth:    maxIntWidth,
+			fractionalWidth: maxFracWidth,
+		}
+	}
+
+	return widths
+}
+
+// splitNumber splits a number string into integer and fractional parts
+// For hex floats like "0x1.92p+06", splits at the decimal point
+// For regular decimals like "100.5", splits at the decimal point
+// Returns (integerPart, fractionalPart) where fractionalPart includes the decimal point
+func splitNumber(str string) (string, string) {
+	// Handle hex floating point format (e.g., "0x1.92p+06")
+	if strings.HasPrefix(str, "0x") && strings.Contains(str, ".") {
+		parts := strings.SplitN(str, ".", 2)
+		return parts[0], "." + parts[1]
+	}
+	// Handle regular decimal format
+	if strings.Contains(str, ".") {
+		parts := strings.SplitN(str, ".", 2)
+		return parts[0], "." + parts[1]
+	}
+	// Integer - no fractional part
+	return str, ""
+}
+
+// return list of bases to display based on command-line flags
+func getEnabledBases(opts *Options) []int {
+	bases := []int{10} // Always show decimal
+	if opts.ShowHex {
+		bases = append(bases, 16)
+	}
+	if opts.ShowBinary {
+		bases = append(bases, 2)
+	}
+	if opts.ShowOctal {
+		bases = append(bases, 8)
+	}
+	return bases
+}
+
+// return list of special formats to display based on command-line flags
+func getEnabledFormats(opts *Options) []string {
+	var formats []string
+	if opts.ShowRational {
+		formats = append(formats, "rational")
+	}
+	if opts.ShowIEEE32 {
+		formats = append(formats, "ieee32")
+	}
+	if opts.ShowIEEE64 {
+		formats = append(formats, "ieee64")
+	}
+	if opts.ShowIPv4 {
+		formats = append(formats, "ipv4")
+	}
+	if opts.ShowFactor {
+		formats = append(formats, "factor")
+	}
+	return formats
+}
+
+// maxRationalWidth returns the max string width of rational representations across all values
+func maxRationalWidth(values []Value) int {
+	max := 0
+	for _, value := range values {
+		w := len(toRational(value.number))
+		if w > max {
+			max = w
+		}
+	}
+	return max
+}
+
+// maxIPv4Width returns the max string width of IPv4 representations across all values
+func maxIPv4Width(values []Value) int {
+	max := 0
+	for _, value := range values {
+		if value.number.isIntegral() {
+			if s := toIPv4(value.number); s != "" {
+				if len(s) > max {
+					max = len(s)
+				}
+			}
+		}
+	}
+	return max
+}
+
+// displayValues returns values as they are displayed, in preferred units and rescaled to SI prefixes with AutoPrefix
+func displayValues(values []Value, opts *Options) []Value {
+	displayed := make([]Value, len(values))
+	for i, value := range values {
+		displayed[i] = value.display(opts)
+	}
+	return displayed
+}
+
+// Print writes the stack to w, top of stack first, aligned in the columns selected in opts
+func (s *Stack) Print(w io.Writer, opts *Options) {
+	opts = s.options(opts)
+	values := displayValues(s.values, opts)
+	widths := maxWidths(values, opts)
+	bases := getEnabledBases(opts)
+	formats := getEnabledFormats(opts)
+
+	var rationalWidth int
+	if opts.ShowRational {
+		rationalWidth = maxRationalWidth(values)
+	}
+	var ipv4Width int
+	if opts.ShowIPv4 {
+		ipv4Width = maxIPv4Width(values)
+	}
+
+	for i := len(values) - 1; i >= 0; i-- {
+		value := values[i]
+		separator := ""
+
+		// Check if this has a time unit that should be displayed in time format
+		hasTimeUnit := value.units[Time].power == 1 && (value.units[Time].name == "hr" || value.units[Time].name == "min") &&
+			!value.number.isComplex()
+
+		if hasTimeUnit {
+			// Format the number part as time
+			timeNumStr := ""
+			if value.units[Time].name == "hr" {
+				timeNumStr = value.formatTimeAsHours()
+			} else if value.units[Time].name == "min" {
+				timeNumStr = value.formatTimeAsMinutes()
+			}
+
+			// Print the formatted time number
+			fmt.Fprintf(w, "%s", timeNumStr)
+
+			// Add units if present
+			if !value.units.empty() {
+				fmt.Fprintf(w, " %s", value.units.Format(opts))
+			}
+		} else if composite, ok := value.formatComposite(opts); ok {
+			// A whole number of each unit but the last (5 ft 10.8661 in)
+			fmt.Fprintf(w, "%s", composite)
+		} else {
+			// Print each enabled base (normal logic)
+			for _, base := range bases {
+				// Skip binary and octal for non-integral numbers
+				// For hex, skip non-integral numbers unless showHexFloat is enabled
+				if base != 10 && !value.number.isIntegral() {
+					if base != 16 || !opts.ShowHexFloat {
+						continue
+					}
+				}
+
+				str := toString(value.number, base, opts)
+				intPart, fracPart := splitNumber(str)
+				colWidth := widths[base]
+
+				// Print with units digit alignment: right-align integer part, left-align fractional part
+				fmt.Fprintf(w, "%s%*s%s", separator, colWidth.integerWidth, intPart, fracPart)
+
+				// Pad fractional part to maintain column alignment
+				padding := colWidth.fractionalWidth - utf8.RuneCountInString(fracPart)
+				if padding > 0 {
+					fmt.Fprintf(w, "%*s", padding, "")
+				}
+
+				separator = "  " // Two spaces between columns
+			}
+
+			// Print special formats (IPv4, rational, factor, etc.)
+			for _, format := range formats {
+				switch format {
+				case "ipv4":
+					if value.number.isIntegral() {
+						ipv4Str := toIPv4(value.number)
+						if ipv4Str != "" {
+							fmt.Fprintf(w, "%s%*s", separator, ipv4Width, ipv4Str)
+							separator = "  "
+						}
+					}
+				case "rational":
+					fmt.Fprintf(w, "%s%*s", separator, rationalWidth, toRational(value.number))
+					separator = "  "
+				case "factor":
+					if value.number.isIntegral() {
+						factorStr := toFactor(value.number)
+						if factorStr != "" {
+							fmt.Fprintf(w, "%s%s", separator, factorStr)
+							separator = "  "
+						}
+					}
+				case "ieee32":
+					if !value.number.isComplex() {
+						fmt.Fprintf(w, "%s%s", separator, toIEEE32(value.number, opts.ShowBinary))
+						separator = "  "
+					}
+				case "ieee64":
+					if !value.number.isComplex() {
+						fmt.Fprintf(w, "%s%s", separator, toIEEE64(value.number, opts.ShowBinary))
+						separator = "  "
+					}
+				}
+			}
+
+			// Add units if present
+			if !value.units.empty() {
+				fmt.Fprintf(w, " %s", value.units.Format(opts))
+			}
+		}
+
+		fmt.Fprintln(w)
+	}
+}
+
+// PrintRegisters writes the named registers to w, sorted by name
+func (s *Stack) PrintRegisters(w io.Writer, opts *Options) {
+	opts = s.options(opts)
+	names := make([]string, 0, len(s.registers))
+	width := 0
+	for name := range s.registers {
+		names = append(names, name)
+		width = max(width, len(name))
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(w, "%*s: %s\n", width, name, s.registers[name].Format(opts))
+	}
+}
+
+// Statistical stack operations
+
+// checkReal checks that no value on the stack is complex, for op, which compares them
+func (s *Stack) checkReal(op string) error {
+	for _, value := range s.values {
+		if value.number.isComplex() {
+			return fmt.Errorf("%w: '%s' does not accept complex values", ErrDomain, op)
+		}
+	}
+	return nil
+}
+
+func (s *Stack) min(replace bool) error {
+	if len(s.values) == 0 {
+		return fmt.Errorf("%w for 'min'", ErrStackUnderflow)
+	}
+	if err := s.checkReal("min"); err != nil {
+		return err
+	}
+
+	minVal := s.values[0]
+	for i := 1; i < len(s.values); i++ {
+		// Convert values to compatible units before comparison
+		current := s.values[i]
+		if !minVal.units.compatible(current.units) {
+			return fmt.Errorf("%w for 'min': %s vs %s", ErrIncompatibleUnits, minVal.units.Name(), current.units.Name())
+		}
+
+		// Convert current to minVal's units for comparison
+		currentConverted, err := current.apply(minVal.units)
+		if err != nil {
+			return err
+		}
+
+		// Compare the numbers (assuming both are now in same units)
+		if currentConverted.number.Rat.Cmp(minVal.number.Rat) < 0 {
+			minVal = currentConverted
+		}
+	}
+
+	if replace {
+		// Clear stack and push minimum value
+		s.values = []Value{minVal}
+	} else {
+		// Push minimum value onto existing stack
+		s.push(minVal)
+	}
+	return nil
+}
+
+func (s *Stack) max(replace bool) error {
+	if len(s.values) == 0 {
+		return fmt.Errorf("%w for 'max'", ErrStackUnderflow)
+	}
+	if err := s.checkReal("max"); err != nil {
+		return err
+	}
+
+	maxVal := s.values[0]
+	for i := 1; i < len(s.values); i++ {
+		// Convert values to compatible units before comparison
+		current := s.values[i]
+		if !maxVal.units.compatible(current.units) {
+			return fmt.Errorf("%w for 'max': %s vs %s", ErrIncompatibleUnits, maxVal.units.Name(), current.units.Name())
+		}
+
+		// Convert current to maxVal's units for comparison
+		currentConverted, err := current.apply(maxVal.units)
+		if err != nil {
+			return err
+		}
+
+		// Compare the numbers (assuming both are now in same units)
+		if currentConverted.number.Rat.Cmp(maxVal.number.Rat) > 0 {
+			maxVal = currentConverted
+		}
+	}
+
+	if replace {
+		// Clear stack and push maximum value
+		s.values = []Value{maxVal}
+	} else {
+		// Push maximum value onto existing stack
+		s.push(maxVal)
+	}
+	return nil
+}
+
+func (s *Stack) mean(replace bool) error {
+	if len(s.values) == 0 {
+		return fmt.Errorf("%w for 'mean'", ErrStackUnderflow)
+	}
+
+	// All values must have compatible units
+	baseUnit := s.values[0].units
+	sum := s.values[0]
+	originalCount := len(s.values)
+
+	for i := 1; i < len(s.values); i++ {
+		current := s.values[i]
+		if !baseUnit.compatible(current.units) {
+			return fmt.Errorf("%w for 'mean': %s vs %s", ErrIncompatibleUnits, baseUnit.Name(), current.units.Name())
+		}
+
+		// Convert to base units and add
+		currentConverted, err := current.apply(baseUnit)
+		if err != nil {
+			return err
+		}
+		if sum, err = sum.binaryOp("+", currentConverted, s.precision); err != nil {
+			return err
+		}
+	}
+
+	// Divide by count
+	count := newNumber(originalCount)
+	countVal := Value{number: count}
+	result, err := sum.binaryOp("/", countVal, s.precision)
+	if err != nil {
+		return err
+	}
+
+	if replace {
+		// Clear stack and push mean
+		s.values = []Value{result}
+	} else {
+		// Push mean onto existing stack
+		s.push(result)
+	}
+	return nil
+}
+
+func (s *Stack) stackSize(replace bool) {
+	// Get the size of the stack
+	size := newNumber(len(s.values))
+	sizeVal := Value{number: size}
+
+	if replace {
+		// Replace stack with size
+		s.values = []Value{sizeVal}
+	} else {
+		// Push size onto existing stack
+		s.push(sizeVal)
+	}
+}
+
+// PrintStats writes a statistics summary of the stack to w
+func (s *Stack) PrintStats(w io.Writer, opts *Options) {
+	opts = s.options(opts)
+	if len(s.values) == 0 {
+		fmt.Fprintf(w, "Statistics: no values\n")
+		return
+	}
+
+	// Get all values in compatible units for statistics
+	baseUnit := s.values[0].units
+	var convertedValues []*Number
+
+	for i, val := range s.values {
+		if !baseUnit.compatible(val.units) {
+			fmt.Fprintf(w, "Statistics: incompatible units %s vs %s - cannot compute statistics\n", baseUnit.Name(), val.units.Name())
+			return
+		}
+		// Complex numbers have no order, so no min, max or range
+		if val.number.isComplex() {
+			fmt.Fprintf(w, "Statistics: %v: complex value %s - cannot compute statistics\n", ErrDomain, val.Format(opts))
+			return
+		}
+
+		if i == 0 {
+			convertedValues = append(convertedValues, val.number)
+		} else {
+			converted, err := val.apply(baseUnit)
+			if err != nil {
+				fmt.Fprintf(w, "Statistics: %v - cannot compute statistics\n", err)
+				return
+			}
+			convertedValues = append(convertedValues, converted.number)
+		}
+	}
+
+	// Calculate statistics
+	count := len(convertedValues)
+
+	// Sum
+	sum := convertedValues[0]
+	for i := 1; i < len(convertedValues); i++ {
+		sum = add(sum, convertedValues[i])
+	}
+
+	// Mean
+	countNum := newNumber(count)
+	mean := div(sum, countNum)
+
+	// Min and Max
+	min := convertedValues[0]
+	max := convertedValues[0]
+	for i := 1; i < len(convertedValues); i++ {
+		if convertedValues[i].Rat.Cmp(min.Rat) < 0 {
+			min = convertedValues[i]
+		}
+		if convertedValues[i].Rat.Cmp(max.Rat) > 0 {
+			max = convertedValues[i]
+		}
+	}
+
+	// Range
+	rangeVal := sub(max, min)
+
+	// Format units string
+	unitsStr := baseUnit.Format(opts)
+	if unitsStr != "" {
+		unitsStr = " " + unitsStr
+	}
+
+	// Print statistics
+	fmt.Fprintf(w, "Statistics:\n")
+	fmt.Fprintf(w, "  count: %d\n", count)
+	fmt.Fprintf(w, "  sum:   %s%s\n", sum.format(opts.Precision), unitsStr)
+	fmt.Fprintf(w, "  min:   %s%s\n", min.format(opts.Precision), unitsStr)
+	fmt.Fprintf(w, "  max:   %s%s\n", max.format(opts.Precision), unitsStr)
+	fmt.Fprintf(w, "  range: %s%s\n", rangeVal.format(opts.Precision), unitsStr)
+	fmt.Fprintf(w, "  mean:  %s%s\n", mean.format(opts.Precision), unitsStr)
+}
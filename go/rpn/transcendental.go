@@ -0,0 +1,361 @@
+// Copyright 2024 Mike Carlton
+// Released under terms of the MIT License:
+//   http://www.opensource.org/licenses/mit-license.php
+
+package rpn
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// Results that are not exact rationals (square roots, logs, non-integer powers) are computed
+// with big.Float and rounded to a working precision of significant digits, plus the digits of any integer part;
+// each evaluator works to its display precision, so -p 30 shows 30 correct digits
+const minWorkingDigits = 20
+const guardDigits = 10
+
+// angleDigits are the extra digits of angles computed in radians, as up to 6 are lost converting to arcsec
+// (206265 per radian)
+const angleDigits = 6
+
+// maxDecimalExponent is the largest power of 10 of a computed result, as larger ones take too long to compute
+const maxDecimalExponent = 100_000
+
+// precision is the number of significant digits to which inexact results are computed
+type precision int
+
+// workingPrecision returns the precision for displaying displayPrecision digits
+func workingPrecision(displayPrecision int) precision {
+	return precision(max(displayPrecision, minWorkingDigits))
+}
+
+// bits returns the big.Float precision for p plus extraDigits and the guard digits
+func (p precision) bits(extraDigits int) uint {
+	return uint(math.Ceil(float64(int(p)+extraDigits+guardDigits) * math.Log2(10)))
+}
+
+// integerDigits returns the number of decimal digits in the integer part of x (0 if |x| < 1)
+func integerDigits(x *big.Float) int {
+	integer, _ := x.Int(nil)
+	if integer.Sign() == 0 {
+		return 0
+	}
+	return len(integer.Abs(integer).String())
+}
+
+// fromFloat rounds x to p as an exact decimal Number
+func (p precision) fromFloat(x *big.Float) *Number {
+	if x.Sign() == 0 {
+		return newNumber(0)
+	}
+
+	return newNumber(x.Text('e', int(p)+integerDigits(x)-1))
+}
+
+// intRoot returns the n-th root of x, and whether it is exact; x must be non-negative
+func intRoot(x *big.Int, n int64) (*big.Int, bool) {
+	if x.Sign() == 0 || n == 1 {
+		return new(big.Int).Set(x), true
+	}
+
+	// Newton's method from an initial guess above the root
+	bigN := big.NewInt(n)
+	nMinus1 := big.NewInt(n - 1)
+	root := new(big.Int).Lsh(big.NewInt(1), uint(x.BitLen()/int(n)+1))
+	for {
+		// next = ((n-1)*root + x/root^(n-1)) / n
+		next := new(big.Int).Exp(root, nMinus1, nil)
+		next.Quo(x, next)
+		next.Add(next, new(big.Int).Mul(nMinus1, root))
+		next.Quo(next, bigN)
+		if next.Cmp(root) >= 0 {
+			break
+		}
+		root = next
+	}
+
+	return root, new(big.Int).Exp(root, bigN, nil).Cmp(x) == 0
+}
+
+// exactRoot returns the n-th root of non-negative x if it is rational
+func exactRoot(x *Number, n int64) (*Number, bool) {
+	num, numExact := intRoot(x.Rat.Num(), n)
+	if !numExact {
+		return nil, false
+	}
+	den, denExact := intRoot(x.Rat.Denom(), n)
+	if !denExact {
+		return nil, false
+	}
+
+	return &Number{Rat: new(big.Rat).SetFrac(num, den)}, true
+}
+
+// exactLog returns k if x is exactly base**k for an integer k
+func exactLog(x *Number, base int64) (*Number, bool) {
+	// x = base**k for k >= 0 has denominator 1; for k < 0, numerator 1
+	n := x.Rat.Num()
+	if n.Cmp(big.NewInt(1)) == 0 {
+		n = x.Rat.Denom()
+	} else if !x.Rat.IsInt() {
+		return nil, false
+	}
+
+	k := int64(0)
+	bigBase := big.NewInt(base)
+	remainder := new(big.Int)
+	for quotient := new(big.Int).Set(n); quotient.Cmp(big.NewInt(1)) > 0; k++ {
+		if quotient.QuoRem(quotient, bigBase, remainder); remainder.Sign() != 0 {
+			return nil, false
+		}
+	}
+
+	if !x.Rat.IsInt() {
+		k = -k
+	}
+	return newNumber(k), true
+}
+
+// atanhSeries returns atanh(z) = z + z³/3 + z⁵/5 + ..., for |z| well below 1
+func atanhSeries(z *big.Float) *big.Float {
+	prec := z.Prec()
+	sum := new(big.Float).SetPrec(prec).Set(z)
+	power := new(big.Float).SetPrec(prec).Set(z)
+	z2 := new(big.Float).SetPrec(prec).Mul(z, z)
+	term := new(big.Float).SetPrec(prec)
+
+	for n := int64(3); ; n += 2 {
+		power.Mul(power, z2)
+		term.Quo(power, new(big.Float).SetInt64(n))
+		if term.Sign() == 0 || term.MantExp(nil) < sum.MantExp(nil)-int(prec) {
+			return sum
+		}
+		sum.Add(sum, term)
+	}
+}
+
+// ln2 returns the natural log of 2 = 2 atanh(1/3) to prec bits
+func ln2(prec uint) *big.Float {
+	third := new(big.Float).SetPrec(prec).Quo(big.NewFloat(1), big.NewFloat(3))
+	result := atanhSeries(third)
+	return result.Mul(result, big.NewFloat(2))
+}
+
+// bigLog returns the natural log of positive x to prec bits
+func bigLog(x *big.Float, prec uint) *big.Float {
+	// x = m * 2**k with m in [1/√2, √2), then ln(x) = k ln(2) + 2 atanh((m-1)/(m+1))
+	m := new(big.Float).SetPrec(prec)
+	k := x.MantExp(m)
+	if m.Cmp(big.NewFloat(math.Sqrt2/2)) < 0 {
+		m.SetMantExp(m, 1)
+		k--
+	}
+
+	one := big.NewFloat(1)
+	z := new(big.Float).SetPrec(prec).Sub(m, one)
+	z.Quo(z, new(big.Float).SetPrec(prec).Add(m, one))
+	result := atanhSeries(z)
+	result.Mul(result, big.NewFloat(2))
+
+	scaled := ln2(prec)
+	scaled.Mul(scaled, new(big.Float).SetInt64(int64(k)))
+	return result.Add(result, scaled)
+}
+
+// bigExp returns e**x to prec bits
+func bigExp(x *big.Float, prec uint) *big.Float {
+	// x = k ln(2) + r with |r| <= ln(2)/2, then e**x = 2**k * e**r
+	log2 := ln2(prec)
+	kFloat := new(big.Float).SetPrec(prec).Quo(x, log2)
+	kFloat.Add(kFloat, big.NewFloat(0.5*float64(kFloat.Sign())))
+	k, _ := kFloat.Int64()
+
+	r := new(big.Float).SetPrec(prec).Mul(log2, new(big.Float).SetInt64(k))
+	r.Sub(x, r)
+
+	// Taylor series: 1 + r + r²/2! + ...
+	sum := new(big.Float).SetPrec(prec).SetInt64(1)
+	term := new(big.Float).SetPrec(prec).SetInt64(1)
+	for n := int64(1); ; n++ {
+		term.Mul(term, r)
+		term.Quo(term, new(big.Float).SetInt64(n))
+		if term.Sign() == 0 || term.MantExp(nil) < -int(prec) {
+			break
+		}
+		sum.Add(sum, term)
+	}
+
+	return sum.SetMantExp(sum, int(k))
+}
+
+// logBase returns the log of positive x in the given base, exact for integer powers of the base
+func logBase(x *Number, base int64, p precision) *Number {
+	if base != 0 {
+		if result, ok := exactLog(x, base); ok {
+			return result
+		}
+	}
+
+	prec := p.bits(0)
+	result := bigLog(new(big.Float).SetPrec(prec).SetRat(x.Rat), prec)
+	if base != 0 {
+		result.Quo(result, bigLog(new(big.Float).SetPrec(prec).SetInt64(base), prec))
+	}
+	return p.fromFloat(result)
+}
+
+// rationalPow returns x**y for positive x and non-integer y, exact when x has a rational root
+func rationalPow(x, y *Number, p precision) (*Number, error) {
+	if y.Rat.Denom().IsInt64() && y.Rat.Denom().Int64() <= math.MaxUint16 {
+		if root, ok := exactRoot(x, y.Rat.Denom().Int64()); ok {
+			return pow(root, &Number{Rat: new(big.Rat).SetInt(y.Rat.Num())}, p)
+		}
+	}
+
+	// Estimate the decimal exponent of the result to size the precision
+	xFloat := new(big.Float).SetRat(x.Rat)
+	yFloat, _ := y.Rat.Float64()
+	exponent := yFloat * float64(xFloat.MantExp(nil)) * math.Log10(2)
+	if math.Abs(exponent) > maxDecimalExponent {
+		return nil, fmt.Errorf("%w: result of power is out of range", ErrDomain)
+	}
+
+	extra := max(0, int(exponent)) + len(fmt.Sprint(int(math.Abs(exponent))))
+	prec := p.bits(extra)
+	t := bigLog(xFloat.SetPrec(prec).SetRat(x.Rat), prec)
+	t.Mul(t, new(big.Float).SetPrec(prec).SetRat(y.Rat))
+	return p.fromFloat(bigExp(t, prec)), nil
+}
+
+// atanSeries returns atan(z) = z - z³/3 + z⁵/5 - ..., for |z| well below 1
+func atanSeries(z *big.Float) *big.Float {
+	prec := z.Prec()
+	sum := new(big.Float).SetPrec(prec).Set(z)
+	power := new(big.Float).SetPrec(prec).Set(z)
+	z2 := new(big.Float).SetPrec(prec).Mul(z, z)
+	z2.Neg(z2)
+	term := new(big.Float).SetPrec(prec)
+
+	for n := int64(3); ; n += 2 {
+		power.Mul(power, z2)
+		term.Quo(power, new(big.Float).SetInt64(n))
+		if term.Sign() == 0 || term.MantExp(nil) < sum.MantExp(nil)-int(prec) {
+			return sum
+		}
+		sum.Add(sum, term)
+	}
+}
+
+// bigPi returns π = 16 atan(1/5) - 4 atan(1/239) (Machin's formula) to prec bits
+func bigPi(prec uint) *big.Float {
+	fifth := new(big.Float).SetPrec(prec).Quo(big.NewFloat(1), big.NewFloat(5))
+	result := atanSeries(fifth)
+	result.Mul(result, big.NewFloat(16))
+
+	small := new(big.Float).SetPrec(prec).Quo(big.NewFloat(1), big.NewFloat(239))
+	small = atanSeries(small)
+	small.Mul(small, big.NewFloat(4))
+	return result.Sub(result, small)
+}
+
+// bigSinCos returns sin(x) and cos(x) to prec bits
+func bigSinCos(x *big.Float, prec uint) (*big.Float, *big.Float) {
+	// x = 2πk + r with |r| <= π, then sum the Taylor series for r
+	twoPi := bigPi(prec)
+	twoPi.Mul(twoPi, big.NewFloat(2))
+	kFloat := new(big.Float).SetPrec(prec).Quo(x, twoPi)
+	kFloat.Add(kFloat, big.NewFloat(0.5*float64(kFloat.Sign())))
+	k, _ := kFloat.Int(nil)
+
+	r := new(big.Float).SetPrec(prec).SetInt(k)
+	r.Sub(x, r.Mul(r, twoPi))
+
+	sin := new(big.Float).SetPrec(prec)
+	cos := new(big.Float).SetPrec(prec).SetInt64(1)
+	term := new(big.Float).SetPrec(prec).SetInt64(1)
+	for n := int64(1); ; n++ {
+		// term = r**n / n!, alternating in sign every two terms
+		term.Mul(term, r)
+		term.Quo(term, new(big.Float).SetInt64(n))
+		if term.Sign() == 0 || term.MantExp(nil) < -int(prec) {
+			break
+		}
+
+		sum := cos
+		if n%2 == 1 {
+			sum = sin
+		}
+		if n%4 < 2 {
+			sum.Add(sum, term)
+		} else {
+			sum.Sub(sum, term)
+		}
+	}
+
+	return sin, cos
+}
+
+// bigAtan returns atan(x) to prec bits
+func bigAtan(x *big.Float, prec uint) *big.Float {
+	// atan(x) = π/2 - atan(1/x) for |x| > 1, and halve the argument twice
+	// with atan(x) = 2 atan(x / (1 + sqrt(1 + x²))) so the series converges quickly
+	z := new(big.Float).SetPrec(prec).Abs(x)
+	inverted := z.Cmp(big.NewFloat(1)) > 0
+	if inverted {
+		z.Quo(big.NewFloat(1), z)
+	}
+
+	for range 2 {
+		root := new(big.Float).SetPrec(prec).Mul(z, z)
+		root.Add(root, big.NewFloat(1))
+		root.Sqrt(root)
+		z.Quo(z, root.Add(root, big.NewFloat(1)))
+	}
+
+	result := atanSeries(z)
+	result.Mul(result, big.NewFloat(4))
+	if inverted {
+		halfPi := bigPi(prec)
+		halfPi.Quo(halfPi, big.NewFloat(2))
+		result.Sub(halfPi, result)
+	}
+	if x.Sign() < 0 {
+		result.Neg(result)
+	}
+	return result
+}
+
+// smallBits returns the extra bits needed where a result loses precision for small |x|
+func smallBits(x *big.Float) uint {
+	return uint(max(0, -x.MantExp(nil)))
+}
+
+// trigArg converts x to a big.Float with enough precision to reduce it modulo 2π
+func (p precision) trigArg(x *Number) (*big.Float, uint) {
+	prec := p.bits(integerDigits(new(big.Float).SetRat(x.Rat)))
+	arg := new(big.Float).SetPrec(prec).SetRat(x.Rat)
+	return arg, prec + smallBits(arg)
+}
+
+// bigAtan2 returns the angle of the point (y, x), not both zero, to prec bits
+func bigAtan2(y, x *big.Rat, prec uint) *big.Float {
+	pi := bigPi(prec)
+	if x.Sign() == 0 {
+		// on the y axis: ±π/2
+		return pi.Quo(pi, big.NewFloat(float64(2*y.Sign())))
+	}
+
+	ratio := new(big.Float).SetPrec(prec).SetRat(new(big.Rat).Quo(y, x))
+	result := bigAtan(ratio, prec+smallBits(ratio))
+	if x.Sign() < 0 {
+		// left half plane: add or subtract π
+		if y.Sign() < 0 {
+			result.Sub(result, pi)
+		} else {
+			result.Add(result, pi)
+		}
+	}
+	return result
+}
@@ -0,0 +1,1633 @@
+// Copyright 2024-2025 Mike Carlton
+// Released under terms of the MIT License:
+//   http://www.opensource.org/licenses/mit-license.php
+
+package rpn
+
+import (
+	"fmt"
+	"maps"
+	"math/big"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Dimension represents the type of unit (Length, Time, Mass, Temperature, etc).
+type Dimension int
+
+const (
+	Mass Dimension = iota
+	Length
+	Time
+	Current
+	Temperature
+	Amount
+	Luminous
+	Currency
+	Angle
+	Information
+	NumDimension // the number of built-in dimensions; an Evaluator numbers its own from here
+)
+
+// dimensionNames are the names of the built-in dimensions, indexed by Dimension
+var dimensionNames = []string{
+	Mass:        "mass",
+	Length:      "length",
+	Time:        "time",
+	Current:     "current",
+	Temperature: "temperature",
+	Amount:      "amount",
+	Luminous:    "luminous intensity",
+	Currency:    "currency",
+	Angle:       "angle",
+	Information: "information",
+}
+
+// String returns the name of a built-in dimension; those of an Evaluator are named by its definitions
+func (d Dimension) String() string {
+	if d < NumDimension {
+		return dimensionNames[d]
+	}
+	return fmt.Sprintf("dimension %d", d)
+}
+
+type BaseUnit struct {
+	name           string
+	description    string
+	dimension      Dimension
+	factor         *Number                                            // for simple scaling, nil for dynamic conversion
+	span           int                                                // the power of dimension one unit is (2 for acre, 3 for l), 0 for 1
+	delta          bool                                               // only applicable to Temperature
+	factorFunction func(*Number, BaseUnit, BaseUnit) (*Number, error) // dynamic conversion function
+}
+
+type UnitPower struct {
+	BaseUnit
+	power int
+}
+
+// count returns the power of u in its own unit, e.g. 2 for acre² (length⁴)
+func (u UnitPower) count() int {
+	if u.span == 0 {
+		return u.power
+	}
+	return u.power / u.span
+}
+
+// fits reports whether power of the dimension of u is a whole number of u (length⁴ is acre², length³ is not)
+func (u BaseUnit) fits(power int) bool {
+	return u.span == 0 || power%u.span == 0
+}
+
+// scale returns the size of u in the SI unit of its dimension (acre² in m⁴), nil for dynamic conversion
+func (u UnitPower) scale() *Number {
+	if u.factor == nil {
+		return nil
+	}
+	return intPow(u.factor, u.count())
+}
+
+// Unit holds the power of each dimension of a value and the unit it is in; only the dimensions used are
+// present, and a missing dimension reads as power 0. Units are maps, so clone one before changing it
+type Unit map[Dimension]UnitPower
+
+// clone returns a copy of v, without any dimensions of power 0, that can be changed without changing v
+func (v Unit) clone() Unit {
+	units := make(Unit, len(v))
+	for dim, unit := range v {
+		if unit.power != 0 {
+			units[dim] = unit
+		}
+	}
+	return units
+}
+
+// dimensions returns the dimensions of v with a non-zero power, in order
+func (v Unit) dimensions() []Dimension {
+	dimensions := make([]Dimension, 0, len(v))
+	for dim, unit := range v {
+		if unit.power != 0 {
+			dimensions = append(dimensions, dim)
+		}
+	}
+	slices.Sort(dimensions)
+	return dimensions
+}
+
+// dimensionsOf returns the dimensions with a non-zero power in any of units, in order
+func dimensionsOf(units ...Unit) []Dimension {
+	var dimensions []Dimension
+	for _, u := range units {
+		for _, dim := range u.dimensions() {
+			if !slices.Contains(dimensions, dim) {
+				dimensions = append(dimensions, dim)
+			}
+		}
+	}
+	slices.Sort(dimensions)
+	return dimensions
+}
+
+// UNITS are the built-in units; Evaluator.DefineUnit adds to those of one evaluator
+// conversion factors are exact rational numbers to preserve precision
+var UNITS = map[string]Unit{
+	// length
+	"m": {
+		Length: UnitPower{BaseUnit{name: "m", description: "meters", dimension: Length, factor: newNumber(1)}, 1},
+	},
+
+	"in": {
+		Length: UnitPower{BaseUnit{name: "in", description: "inches", dimension: Length, factor: newRationalNumber(254, 10_000)}, 1},
+	},
+	"ft": {
+		Length: UnitPower{BaseUnit{name: "ft", description: "feet", dimension: Length, factor: newRationalNumber(254*12, 10_000)}, 1},
+	},
+	"yd": {
+		Length: UnitPower{BaseUnit{name: "yd", description: "yards", dimension: Length, factor: newRationalNumber(254*36, 10_000)}, 1},
+	},
+	"mi": {
+		Length: UnitPower{BaseUnit{name: "mi", description: "miles", dimension: Length, factor: newRationalNumber(254*12*5280, 10_000)}, 1},
+	},
+	"nmi": {
+		Length: UnitPower{BaseUnit{name: "nmi", description: "nautical miles", dimension: Length, factor: newNumber(1852)}, 1},
+	},
+	"au": {
+		Length: UnitPower{BaseUnit{name: "au", description: "astronomical units", dimension: Length, factor: newNumber(149_597_870_700)}, 1},
+	},
+	"ly": { // julian year at c
+		Length: UnitPower{BaseUnit{name: "ly", description: "light years", dimension: Length, factor: newNumber(9_460_730_472_580_800)}, 1},
+	},
+
+	// mass
+	"g": {
+		Mass: UnitPower{BaseUnit{name: "g", description: "grams", dimension: Mass, factor: newNumber(1)}, 1},
+	},
+
+	"oz": {
+		Mass: UnitPower{BaseUnit{name: "oz", description: "ounces", dimension: Mass, factor: newRationalNumber(45359237, 16*100_000)}, 1},
+	},
+	"lb": {
+		Mass: UnitPower{BaseUnit{name: "lb", description: "pounds", dimension: Mass, factor: newRationalNumber(45359237, 100_000)}, 1},
+	},
+	"st": {
+		Mass: UnitPower{BaseUnit{name: "st", description: "stone", dimension: Mass, factor: newRationalNumber(14*45359237, 100_000)}, 1},
+	},
+	"tonne": { // in place of the symbol t, which is the truncate operator
+		Mass: UnitPower{BaseUnit{name: "tonne", description: "tonnes", dimension: Mass, factor: newNumber(1_000_000)}, 1},
+	},
+
+	// area -- non-SI unit, accepted for use with SI units, 1 ha = 10000 m² by definition
+	"ha": {
+		Length: UnitPower{BaseUnit{name: "ha", description: "hectares", dimension: Length, factor: newNumber(10_000), span: 2}, 2},
+	},
+
+	"acre": { // 1 acre = 66x660 ft = 43,560 square feet = 4046.8564224 square meters by definition (0.3048 m/ft * 0.3048 m/ft * 43560 ft²/acre)
+		Length: UnitPower{BaseUnit{name: "acre", description: "acres", dimension: Length, factor: newRationalNumber(3048*3048*43560, 100_000_000), span: 2}, 2},
+	},
+
+	// volume -- non-SI unit, accepted for use with SI units, 1 l = 1000 cubic centimeters = 0.001 m³ by definition
+	"l": {
+		Length: UnitPower{BaseUnit{name: "l", description: "liters", dimension: Length, factor: newRationalNumber(1, 1000), span: 3}, 3},
+	},
+	"cc": { // cc = 1 cubic centimeter = 1 milliliter = 0.000001 m³ by definition
+		Length: UnitPower{BaseUnit{name: "cc", description: "cubic centimeters", dimension: Length, factor: newRationalNumber(1, 1_000_000), span: 3}, 3},
+	},
+
+	// us gallon = 231 in³ = 3.785411784 l by definition
+	"foz": {
+		Length: UnitPower{BaseUnit{name: "foz", description: "fl. ounces", dimension: Length, factor: newRationalNumber(3785411784, 128*1_000_000_000_000), span: 3}, 3},
+	},
+	"cup": {
+		Length: UnitPower{BaseUnit{name: "cup", description: "cups", dimension: Length, factor: newRationalNumber(3785411784, 16*1_000_000_000_000), span: 3}, 3},
+	},
+	"pt": {
+		Length: UnitPower{BaseUnit{name: "pt", description: "pints", dimension: Length, factor: newRationalNumber(3785411784, 8*1_000_000_000_000), span: 3}, 3},
+	},
+	"qt": {
+		Length: UnitPower{BaseUnit{name: "qt", description: "quarts", dimension: Length, factor: newRationalNumber(3785411784, 4*1_000_000_000_000), span: 3}, 3},
+	},
+	"gal": {
+		Length: UnitPower{BaseUnit{name: "gal", description: "us gallons", dimension: Length, factor: newRationalNumber(3785411784, 1_000_000_000_000), span: 3}, 3},
+	},
+	"ukpt": {
+		Length: UnitPower{BaseUnit{name: "ukpt", description: "imperial pints", dimension: Length, factor: newRationalNumber(454_609, 8*100_000_000), span: 3}, 3},
+	},
+	"ukgal": {
+		Length: UnitPower{BaseUnit{name: "ukgal", description: "imperial gallons", dimension: Length, factor: newRationalNumber(454_609, 100_000_000), span: 3}, 3},
+	},
+
+	// temperature
+	"C": {
+		Temperature: UnitPower{BaseUnit{name: "°C", description: "celsius", dimension: Temperature, factorFunction: temperatureConvert}, 1},
+	},
+	"°C": {
+		Temperature: UnitPower{BaseUnit{name: "°C", description: "celsius", dimension: Temperature, factorFunction: temperatureConvert}, 1},
+	},
+	"F": {
+		Temperature: UnitPower{BaseUnit{name: "°F", description: "farenheit", dimension: Temperature, factorFunction: temperatureConvert}, 1},
+	},
+	"°F": {
+		Temperature: UnitPower{BaseUnit{name: "°F", description: "farenheit", dimension: Temperature, factorFunction: temperatureConvert}, 1},
+	},
+	"dC": {
+		Temperature: UnitPower{BaseUnit{name: "°CΔ", description: "delta celsius", dimension: Temperature, delta: true, factor: newNumber(1)}, 1},
+	},
+	"dF": {
+		Temperature: UnitPower{BaseUnit{name: "°FΔ", description: "delta farenheit", dimension: Temperature, delta: true, factor: newRationalNumber(5, 9)}, 1},
+	},
+
+	// absolute scales start at absolute zero, so their differences need no delta units (except for °RΔ, to match °FΔ)
+	"K": {
+		Temperature: UnitPower{BaseUnit{name: "K", description: "kelvin", dimension: Temperature, factor: newNumber(1)}, 1},
+	},
+	"°R": {
+		Temperature: UnitPower{BaseUnit{name: "°R", description: "rankine", dimension: Temperature, factor: newRationalNumber(5, 9)}, 1},
+	},
+	"dR": {
+		Temperature: UnitPower{BaseUnit{name: "°RΔ", description: "delta rankine", dimension: Temperature, delta: true, factor: newRationalNumber(5, 9)}, 1},
+	},
+
+	// amount of substance
+	"mol": {
+		Amount: UnitPower{BaseUnit{name: "mol", description: "moles", dimension: Amount, factor: newNumber(1)}, 1},
+	},
+
+	// luminous intensity -- steradians are dimensionless, so lumens (cd·sr) are candelas in a different name
+	"cd": {
+		Luminous: UnitPower{BaseUnit{name: "cd", description: "candelas", dimension: Luminous, factor: newNumber(1)}, 1},
+	},
+	"lm": {
+		Luminous: UnitPower{BaseUnit{name: "lm", description: "lumens", dimension: Luminous, factor: newNumber(1)}, 1},
+	},
+
+	// time
+	"s": {
+		Time: UnitPower{BaseUnit{name: "s", description: "seconds", dimension: Time, factor: newNumber(1)}, 1},
+	},
+	"min": {
+		Time: UnitPower{BaseUnit{name: "min", description: "minutes", dimension: Time, factor: newNumber(60)}, 1},
+	},
+	"hr": {
+		Time: UnitPower{BaseUnit{name: "hr", description: "hours", dimension: Time, factor: newNumber(3600)}, 1},
+	},
+	"day": {
+		Time: UnitPower{BaseUnit{name: "day", description: "days", dimension: Time, factor: newNumber(86_400)}, 1},
+	},
+	"wk": {
+		Time: UnitPower{BaseUnit{name: "wk", description: "weeks", dimension: Time, factor: newNumber(7 * 86_400)}, 1},
+	},
+	"yr": { // julian year
+		Time: UnitPower{BaseUnit{name: "yr", description: "years", dimension: Time, factor: newNumber(36_525 * 864)}, 1},
+	},
+	// frequency -- revolutions are dimensionless, so rpm is per minute
+	"rpm": {
+		Time: UnitPower{BaseUnit{name: "rpm", description: "revolutions per minute", dimension: Time, factor: newNumber(60)}, -1},
+	},
+
+	// speed
+	"mph": {
+		Length: UnitPower{BaseUnit{name: "mi", description: "miles", dimension: Length, factor: newRationalNumber(254*12*5280, 10_000)}, 1},
+		Time:   UnitPower{BaseUnit{name: "hr", description: "hours", dimension: Time, factor: newNumber(3600)}, -1},
+	},
+	"kph": {
+		Length: UnitPower{BaseUnit{name: "km", description: "kilometers", dimension: Length, factor: newNumber(1_000)}, 1},
+		Time:   UnitPower{BaseUnit{name: "hr", description: "hours", dimension: Time, factor: newNumber(3600)}, -1},
+	},
+	"kn": {
+		Length: UnitPower{BaseUnit{name: "nmi", description: "nautical miles", dimension: Length, factor: newNumber(1852)}, 1},
+		Time:   UnitPower{BaseUnit{name: "hr", description: "hours", dimension: Time, factor: newNumber(3600)}, -1},
+	},
+
+	// current
+	"A": {
+		Current: UnitPower{BaseUnit{name: "A", description: "amperes", dimension: Current, factor: newNumber(1)}, 1},
+	},
+
+	// currency - USD is base (uses factor), others use dynamic conversion
+	"usd": {
+		Currency: UnitPower{BaseUnit{name: "usd", description: "us dollars", dimension: Currency, factor: newNumber(1)}, 1},
+	},
+	"$": {
+		Currency: UnitPower{BaseUnit{name: "$", description: "us dollars", dimension: Currency, factor: newNumber(1)}, 1},
+	},
+	"eur": {
+		Currency: UnitPower{BaseUnit{name: "eur", description: "euros", dimension: Currency, factorFunction: currencyConvert}, 1},
+	},
+	"€": {
+		Currency: UnitPower{BaseUnit{name: "€", description: "euros", dimension: Currency, factorFunction: currencyConvert}, 1},
+	},
+	"gbp": {
+		Currency: UnitPower{BaseUnit{name: "gbp", description: "british pounds", dimension: Currency, factorFunction: currencyConvert}, 1},
+	},
+	"£": {
+		Currency: UnitPower{BaseUnit{name: "£", description: "british pounds", dimension: Currency, factorFunction: currencyConvert}, 1},
+	},
+	"yen": {
+		Currency: UnitPower{BaseUnit{name: "yen", description: "japanese yen", dimension: Currency, factorFunction: currencyConvert}, 1},
+	},
+	"jpy": {
+		Currency: UnitPower{BaseUnit{name: "jpy", description: "japanese yen", dimension: Currency, factorFunction: currencyConvert}, 1},
+	},
+	"¥": {
+		Currency: UnitPower{BaseUnit{name: "¥", description: "japanese yen", dimension: Currency, factorFunction: currencyConvert}, 1},
+	},
+	"btc": {
+		Currency: UnitPower{BaseUnit{name: "btc", description: "bitcoin", dimension: Currency, factorFunction: currencyConvert}, 1},
+	},
+
+	// angle -- radians are the base unit, plain numbers are treated as radians by trigonometric functions
+	"rad": {
+		Angle: UnitPower{BaseUnit{name: "rad", description: "radians", dimension: Angle, factor: newNumber(1)}, 1},
+	},
+	"deg": {
+		Angle: UnitPower{BaseUnit{name: "deg", description: "degrees", dimension: Angle, factor: div(Pi, newNumber(180))}, 1},
+	},
+	"grad": {
+		Angle: UnitPower{BaseUnit{name: "grad", description: "gradians", dimension: Angle, factor: div(Pi, newNumber(200))}, 1},
+	},
+	"arcmin": {
+		Angle: UnitPower{BaseUnit{name: "arcmin", description: "arc-minutes", dimension: Angle, factor: div(Pi, newNumber(180*60))}, 1},
+	},
+	"arcsec": {
+		Angle: UnitPower{BaseUnit{name: "arcsec", description: "arc-seconds", dimension: Angle, factor: div(Pi, newNumber(180*60*60))}, 1},
+	},
+
+	// information -- bits are the base unit; MAGNITUDE suffixes (4G) remain plain numbers
+	"bit": {
+		Information: UnitPower{BaseUnit{name: "bit", description: "bits", dimension: Information, factor: newNumber(1)}, 1},
+	},
+	"B": {
+		Information: UnitPower{BaseUnit{name: "B", description: "bytes", dimension: Information, factor: newNumber(8)}, 1},
+	},
+
+	// derived units
+	// joules J = kg⋅m²⋅s⁻²
+	"J": {
+		Mass:   UnitPower{BaseUnit{name: "kg", dimension: Mass, factor: newNumber(1_000)}, 1},
+		Length: UnitPower{BaseUnit{name: "m", dimension: Length, factor: newNumber(1)}, 2},
+		Time:   UnitPower{BaseUnit{name: "s", dimension: Time, factor: newNumber(1)}, -2},
+	},
+	// newtons N = kg⋅m⋅s⁻²
+	"N": {
+		Mass:   UnitPower{BaseUnit{name: "kg", dimension: Mass, factor: newNumber(1_000)}, 1},
+		Length: UnitPower{BaseUnit{name: "m", dimension: Length, factor: newNumber(1)}, 1},
+		Time:   UnitPower{BaseUnit{name: "s", dimension: Time, factor: newNumber(1)}, -2},
+	},
+	// volts V = kg⋅m²⋅s⁻³⋅A⁻¹
+	"V": {
+		Mass:    UnitPower{BaseUnit{name: "kg", dimension: Mass, factor: newNumber(1_000)}, 1},
+		Length:  UnitPower{BaseUnit{name: "m", dimension: Length, factor: newNumber(1)}, 2},
+		Time:    UnitPower{BaseUnit{name: "s", dimension: Time, factor: newNumber(1)}, -3},
+		Current: UnitPower{BaseUnit{name: "A", dimension: Current, factor: newNumber(1)}, -1},
+	},
+	// watts W = kg⋅m²⋅s⁻³
+	"W": {
+		Mass:   UnitPower{BaseUnit{name: "kg", dimension: Mass, factor: newNumber(1_000)}, 1},
+		Length: UnitPower{BaseUnit{name: "m", dimension: Length, factor: newNumber(1)}, 2},
+		Time:   UnitPower{BaseUnit{name: "s", dimension: Time, factor: newNumber(1)}, -3},
+	},
+	// ohms Ω = kg⋅m²⋅s⁻³⋅A⁻²
+	"Ω": {
+		Mass:    UnitPower{BaseUnit{name: "kg", dimension: Mass, factor: newNumber(1_000)}, 1},
+		Length:  UnitPower{BaseUnit{name: "m", dimension: Length, factor: newNumber(1)}, 2},
+		Time:    UnitPower{BaseUnit{name: "s", dimension: Time, factor: newNumber(1)}, -3},
+		Current: UnitPower{BaseUnit{name: "A", dimension: Current, factor: newNumber(1)}, -2},
+	},
+	// pascals Pa = kg⋅m⁻¹⋅s⁻²
+	"Pa": {
+		Mass:   UnitPower{BaseUnit{name: "kg", dimension: Mass, factor: newNumber(1_000)}, 1},
+		Length: UnitPower{BaseUnit{name: "m", dimension: Length, factor: newNumber(1)}, -1},
+		Time:   UnitPower{BaseUnit{name: "s", dimension: Time, factor: newNumber(1)}, -2},
+	},
+	// hertz Hz = s⁻¹
+	"Hz": {
+		Time: UnitPower{BaseUnit{name: "s", dimension: Time, factor: newNumber(1)}, -1},
+	},
+	// lux lx = lm⋅m⁻²
+	"lx": {
+		Length:   UnitPower{BaseUnit{name: "m", dimension: Length, factor: newNumber(1)}, -2},
+		Luminous: UnitPower{BaseUnit{name: "lm", dimension: Luminous, factor: newNumber(1)}, 1},
+	},
+	// katals kat = mol⋅s⁻¹
+	"kat": {
+		Time:   UnitPower{BaseUnit{name: "s", dimension: Time, factor: newNumber(1)}, -1},
+		Amount: UnitPower{BaseUnit{name: "mol", dimension: Amount, factor: newNumber(1)}, 1},
+	},
+	// bits per second bps = bit⋅s⁻¹
+	"bps": {
+		Time:        UnitPower{BaseUnit{name: "s", dimension: Time, factor: newNumber(1)}, -1},
+		Information: UnitPower{BaseUnit{name: "bit", dimension: Information, factor: newNumber(1)}, 1},
+	},
+
+	// non-SI units of derived dimensions: as with prefixed units (kW), the scale and name are on the mass
+	// (in grams, so 1 bar is 10⁸ g⋅m⁻¹⋅s⁻²)
+	// pressure
+	"bar": {
+		Mass:   UnitPower{BaseUnit{name: "bar", description: "bars", dimension: Mass, factor: newNumber(100_000_000)}, 1},
+		Length: UnitPower{BaseUnit{name: "m", dimension: Length, factor: newNumber(1)}, -1},
+		Time:   UnitPower{BaseUnit{name: "s", dimension: Time, factor: newNumber(1)}, -2},
+	},
+	"atm": {
+		Mass:   UnitPower{BaseUnit{name: "atm", description: "standard atmospheres", dimension: Mass, factor: newNumber(101_325_000)}, 1},
+		Length: UnitPower{BaseUnit{name: "m", dimension: Length, factor: newNumber(1)}, -1},
+		Time:   UnitPower{BaseUnit{name: "s", dimension: Time, factor: newNumber(1)}, -2},
+	},
+	"psi": { // lbf/in²
+		Mass:   UnitPower{BaseUnit{name: "psi", description: "pounds per square inch", dimension: Mass, factor: newRationalNumber(8_896_443_230_521, 1_290_320)}, 1},
+		Length: UnitPower{BaseUnit{name: "m", dimension: Length, factor: newNumber(1)}, -1},
+		Time:   UnitPower{BaseUnit{name: "s", dimension: Time, factor: newNumber(1)}, -2},
+	},
+	"mmHg": {
+		Mass:   UnitPower{BaseUnit{name: "mmHg", description: "millimeters of mercury", dimension: Mass, factor: newRationalNumber(133_322_387_415, 1_000_000)}, 1},
+		Length: UnitPower{BaseUnit{name: "m", dimension: Length, factor: newNumber(1)}, -1},
+		Time:   UnitPower{BaseUnit{name: "s", dimension: Time, factor: newNumber(1)}, -2},
+	},
+	// energy
+	"cal": { // thermochemical calorie
+		Mass:   UnitPower{BaseUnit{name: "cal", description: "calories", dimension: Mass, factor: newNumber(4_184)}, 1},
+		Length: UnitPower{BaseUnit{name: "m", dimension: Length, factor: newNumber(1)}, 2},
+		Time:   UnitPower{BaseUnit{name: "s", dimension: Time, factor: newNumber(1)}, -2},
+	},
+	"BTU": { // international table BTU
+		Mass:   UnitPower{BaseUnit{name: "BTU", description: "british thermal units", dimension: Mass, factor: newRationalNumber(105_505_585_262, 100_000)}, 1},
+		Length: UnitPower{BaseUnit{name: "m", dimension: Length, factor: newNumber(1)}, 2},
+		Time:   UnitPower{BaseUnit{name: "s", dimension: Time, factor: newNumber(1)}, -2},
+	},
+	"Wh": {
+		Mass:   UnitPower{BaseUnit{name: "Wh", description: "watt-hours", dimension: Mass, factor: newNumber(3_600_000)}, 1},
+		Length: UnitPower{BaseUnit{name: "m", dimension: Length, factor: newNumber(1)}, 2},
+		Time:   UnitPower{BaseUnit{name: "s", dimension: Time, factor: newNumber(1)}, -2},
+	},
+	"eV": {
+		Mass:   UnitPower{BaseUnit{name: "eV", description: "electronvolts", dimension: Mass, factor: div(newNumber(1_602_176_634), intPow(newNumber(10), 25))}, 1},
+		Length: UnitPower{BaseUnit{name: "m", dimension: Length, factor: newNumber(1)}, 2},
+		Time:   UnitPower{BaseUnit{name: "s", dimension: Time, factor: newNumber(1)}, -2},
+	},
+	// force
+	"lbf": {
+		Mass:   UnitPower{BaseUnit{name: "lbf", description: "pounds-force", dimension: Mass, factor: newRationalNumber(8_896_443_230_521, 2_000_000_000)}, 1},
+		Length: UnitPower{BaseUnit{name: "m", dimension: Length, factor: newNumber(1)}, 1},
+		Time:   UnitPower{BaseUnit{name: "s", dimension: Time, factor: newNumber(1)}, -2},
+	},
+	"kgf": {
+		Mass:   UnitPower{BaseUnit{name: "kgf", description: "kilograms-force", dimension: Mass, factor: newRationalNumber(980_665, 100)}, 1},
+		Length: UnitPower{BaseUnit{name: "m", dimension: Length, factor: newNumber(1)}, 1},
+		Time:   UnitPower{BaseUnit{name: "s", dimension: Time, factor: newNumber(1)}, -2},
+	},
+	"dyn": {
+		Mass:   UnitPower{BaseUnit{name: "dyn", description: "dynes", dimension: Mass, factor: newRationalNumber(1, 100)}, 1},
+		Length: UnitPower{BaseUnit{name: "m", dimension: Length, factor: newNumber(1)}, 1},
+		Time:   UnitPower{BaseUnit{name: "s", dimension: Time, factor: newNumber(1)}, -2},
+	},
+	// power
+	"hp": { // mechanical horsepower, 550 ft⋅lbf/s
+		Mass:   UnitPower{BaseUnit{name: "hp", description: "horsepower", dimension: Mass, factor: newRationalNumber(37_284_993_579_113_511, 50_000_000_000)}, 1},
+		Length: UnitPower{BaseUnit{name: "m", dimension: Length, factor: newNumber(1)}, 2},
+		Time:   UnitPower{BaseUnit{name: "s", dimension: Time, factor: newNumber(1)}, -3},
+	},
+}
+
+// SI Prefix definitions with power of 10
+type SIPrefix struct {
+	symbol string
+	name   string
+	power  int // power of 10
+}
+
+var SI_PREFIXES = []SIPrefix{
+	{"da", "deca", 1},
+	{"h", "hecto", 2},
+	{"k", "kilo", 3},
+	{"M", "mega", 6},
+	{"G", "giga", 9},
+	{"T", "tera", 12},
+	{"P", "peta", 15},
+	{"E", "exa", 18},
+
+	{"d", "deci", -1},
+	{"c", "centi", -2},
+	{"m", "milli", -3},
+	{"μ", "micro", -6},
+	{"u", "micro", -6},
+	{"n", "nano", -9},
+	{"p", "pico", -12},
+	{"f", "femto", -15},
+	{"a", "atto", -18},
+}
+
+// IEC binary prefix definitions with power of 2, for information units
+type BinaryPrefix struct {
+	symbol string
+	name   string
+	power  int // power of 2
+}
+
+var BINARY_PREFIXES = []BinaryPrefix{
+	{"Ki", "kibi", 10},
+	{"Mi", "mebi", 20},
+	{"Gi", "gibi", 30},
+	{"Ti", "tebi", 40},
+	{"Pi", "pebi", 50},
+	{"Ei", "exbi", 60},
+	{"Zi", "zebi", 70},
+	{"Yi", "yobi", 80},
+}
+
+// CurrencyConverter converts an amount between currency codes when one of them is USD
+type CurrencyConverter func(amount *Number, from, to string) (*Number, error)
+
+// Supported currency codes
+var supportedCurrencies = map[string]string{
+	"usd": "USD",
+	"$":   "USD",
+	"eur": "EUR",
+	"€":   "EUR",
+	"gbp": "GBP",
+	"£":   "GBP",
+	"yen": "JPY",
+	"jpy": "JPY",
+	"¥":   "JPY",
+	"btc": "BTC",
+}
+
+// getCurrencyCode normalizes currency symbols to standard codes
+func getCurrencyCode(symbol string) (string, bool) {
+	code, exists := supportedCurrencies[strings.ToLower(symbol)]
+	return code, exists
+}
+
+// currencyConvert converts the currency units of UNITS, which have no exchange rates;
+// those an Evaluator parses convert with its Currency instead (see definitions.withRates)
+func currencyConvert(amount *Number, from, to BaseUnit) (*Number, error) {
+	return convertCurrency(nil, amount, from, to)
+}
+
+// convertCurrency handles any currency conversion with exchange rates from convert, including multi-currency via USD
+func convertCurrency(convert CurrencyConverter, amount *Number, from, to BaseUnit) (*Number, error) {
+	fromCode, fromExists := getCurrencyCode(from.name)
+	toCode, toExists := getCurrencyCode(to.name)
+
+	if !fromExists || !toExists {
+		return nil, fmt.Errorf("%w: unsupported currency conversion %s -> %s", ErrConversion, from.name, to.name)
+	}
+	if convert == nil {
+		return nil, fmt.Errorf("%w: no exchange rates available for %s -> %s", ErrConversion, from.name, to.name)
+	}
+
+	var result *Number
+	var err error
+
+	// If either is USD, do direct conversion
+	if fromCode == "USD" || toCode == "USD" {
+		result, err = convert(amount, fromCode, toCode)
+	} else {
+		// Both are non-USD, convert through USD as intermediate
+		// First convert from source to USD
+		usdAmount, err1 := convert(amount, fromCode, "USD")
+		if err1 != nil {
+			return nil, fmt.Errorf("%w: currency conversion error: %v", ErrConversion, err1)
+		}
+
+		// Then convert from USD to target
+		result, err = convert(usdAmount, "USD", toCode)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: currency conversion error: %v", ErrConversion, err)
+	}
+	return result, nil
+}
+
+// Temperature factors are the size of a degree in kelvin; °C and °F also have an offset,
+// the temperature of absolute zero on their scale, and convert with temperatureConvert
+var temperatureOffsets = map[string]*Number{
+	"°C": newRationalNumber(-27_315, 100),
+	"°F": newRationalNumber(-45_967, 100),
+}
+
+var temperatureFactors = map[string]*Number{
+	"°C": newNumber(1),
+	"°F": newRationalNumber(5, 9),
+}
+
+// temperatureScale returns the size of a degree of u in kelvin and the value of absolute zero (nil if 0)
+func temperatureScale(u BaseUnit) (*Number, *Number) {
+	if factor, ok := temperatureFactors[u.name]; ok {
+		return factor, temperatureOffsets[u.name]
+	}
+	return u.factor, nil
+}
+
+// isOffsetTemperature reports whether units are a temperature reading on a scale with an offset (°C or °F),
+// where sums, products and ratios with other temperatures are not meaningful;
+// in compound units (e.g. W/°C) a temperature is always a difference
+func isOffsetTemperature(units Unit) bool {
+	temperature := units[Temperature]
+	if temperature.power != 1 || temperature.delta || temperatureOffsets[temperature.name] == nil {
+		return false
+	}
+
+	for dim, unit := range units {
+		if dim != Temperature && unit.power != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// temperatureConvert handles temperature conversions with proper offset handling
+func temperatureConvert(amount *Number, from, to BaseUnit) (*Number, error) {
+	// Same units, nothing to convert
+	if from.name == to.name {
+		return amount, nil
+	}
+
+	fromFactor, fromOffset := temperatureScale(from)
+	toFactor, toOffset := temperatureScale(to)
+	if fromFactor == nil || toFactor == nil {
+		return nil, fmt.Errorf("%w: unsupported temperature conversion %s -> %s", ErrConversion, from.name, to.name)
+	}
+
+	// A reading with an offset is not a difference: 20°C is not 20°CΔ
+	if !from.delta && to.delta && fromOffset != nil {
+		return nil, fmt.Errorf("%w: cannot convert temperature %s to difference %s", ErrConversion, from.name, to.name)
+	}
+
+	// Offsets only apply between two readings; a delta (e.g. added to a reading) only scales
+	absolute := !from.delta && !to.delta
+	if absolute && fromOffset != nil {
+		amount = sub(amount, fromOffset)
+	}
+	result := div(mul(amount, fromFactor), toFactor)
+	if absolute && toOffset != nil {
+		result = add(result, toOffset)
+	}
+	return result, nil
+}
+
+// dynamicConvert converts amount from the units of dimension dim in units to the unit to,
+// using the factorFunction of either unit
+// A bare unit (the only one, to the first power) converts directly, so 20°C is 68°F; within compound units
+// or powers the conversion is a rate, applied once per power, and temperatures are differences,
+// so 3.50 $/gal converts with the exchange rate and 1 °C/hr is 1.8 °F/hr
+func dynamicConvert(amount *Number, units Unit, dim Dimension, to BaseUnit) (*Number, error) {
+	from := units[dim]
+	convert := to.factorFunction
+	if convert == nil {
+		convert = from.factorFunction
+	}
+	if convert == nil {
+		return nil, fmt.Errorf("%w: no conversion method available for %s -> %s", ErrConversion, from.name, to.name)
+	}
+
+	bare := from.power == 1
+	for i, unit := range units {
+		if i != dim && unit.power != 0 {
+			bare = false
+		}
+	}
+	if bare {
+		return convert(amount, from.BaseUnit, to)
+	}
+
+	fromUnit := from.BaseUnit
+	if dim == Temperature {
+		fromUnit.delta, to.delta = true, true
+	}
+	rate, err := convert(newNumber(1), fromUnit, to)
+	if err != nil {
+		return nil, err
+	}
+	return mul(amount, intPow(rate, from.power)), nil
+}
+
+func init() {
+	generatePrefixedUnits()
+
+	// Word aliases for derived units, with the same prefixes (kohm is kΩ)
+	UNITS["ohm"] = UNITS["Ω"]
+	for _, prefix := range SI_PREFIXES {
+		if unit, exists := UNITS[prefix.symbol+"Ω"]; exists {
+			if _, exists := UNITS[prefix.symbol+"ohm"]; !exists {
+				UNITS[prefix.symbol+"ohm"] = unit
+			}
+		}
+	}
+}
+
+// Units that accept SI prefixes, in order of precedence when prefixed names collide
+var UNITS_FOR_PREFIXES = []string{"m", "g", "s", "l", "A", "K", "mol", "cd", "lm", "J", "N", "V", "W", "Ω", "lx", "kat", "bit", "B", "bps", "Pa", "Hz", "bar", "cal", "Wh", "eV"}
+
+// Units that also accept binary prefixes (KiB is 1024 bytes)
+var UNITS_FOR_BINARY_PREFIXES = []string{"bit", "B"}
+
+// generatePrefixedUnits adds every SI prefix to every unit in UNITS_FOR_PREFIXES
+// When a prefixed name is already a unit, the existing unit wins and the prefixed one is skipped:
+// units in UNITS are never replaced (so min would stay minutes, not milli-in), and among prefixed
+// units the earlier base unit in UNITS_FOR_PREFIXES takes precedence
+// Products of units need a separator (m·s, m.s or m*s), so ms is always milliseconds
+func generatePrefixedUnits() {
+	for _, baseUnitName := range UNITS_FOR_PREFIXES {
+		if baseUnit, exists := UNITS[baseUnitName]; exists {
+			for _, prefix := range SI_PREFIXES {
+				// Information comes in whole bits, and dB would read as decibels
+				if prefix.power < 0 && baseUnit[Information].power != 0 {
+					continue
+				}
+				addPrefixedUnit(baseUnitName, baseUnit, prefix.symbol, prefix.name, intPow(newNumber(10), prefix.power))
+			}
+		}
+	}
+
+	for _, baseUnitName := range UNITS_FOR_BINARY_PREFIXES {
+		if baseUnit, exists := UNITS[baseUnitName]; exists {
+			for _, prefix := range BINARY_PREFIXES {
+				addPrefixedUnit(baseUnitName, baseUnit, prefix.symbol, prefix.name, intPow(newNumber(2), prefix.power))
+			}
+		}
+	}
+}
+
+// addPrefixedUnit adds unit baseUnitName scaled by prefixFactor, unless the prefixed name is already a unit
+func addPrefixedUnit(baseUnitName string, baseUnit Unit, symbol, name string, prefixFactor *Number) {
+	if _, exists := UNITS[symbol+baseUnitName]; exists {
+		return
+	}
+	if unit, ok := prefixedUnit(baseUnitName, baseUnit, symbol, name, prefixFactor); ok {
+		UNITS[symbol+baseUnitName] = unit
+	}
+}
+
+// prefixedUnit returns unit baseUnitName scaled by prefixFactor, named with the prefix symbol
+func prefixedUnit(baseUnitName string, baseUnit Unit, symbol, name string, prefixFactor *Number) (Unit, bool) {
+	prefixedSymbol := symbol + baseUnitName
+
+	// Find the first base unit with power 1 (or -1, as in Hz) and apply prefix factor; ml is a thousandth of l (not of m³)
+	for _, power := range []int{1, -1} {
+		for _, dim := range baseUnit.dimensions() {
+			unit := baseUnit[dim]
+			if unit.count() != power {
+				continue
+			}
+
+			// Make a copy of the entire Unit structure
+			newUnit := baseUnit.clone()
+
+			// Apply prefix factor to this unit's factor
+			factor := unit.factor
+			if factor == nil {
+				factor = newNumber(1)
+			}
+			if power == 1 {
+				unit.factor = mul(factor, prefixFactor)
+			} else {
+				unit.factor = div(factor, prefixFactor)
+			}
+			// Update the name to include prefix
+			unit.name = prefixedSymbol
+			unit.description = name + unit.description
+			newUnit[dim] = unit
+
+			return newUnit, true // Only modify the first unit with power ±1
+		}
+	}
+	return nil, false
+}
+
+var unitNamePattern = regexp.MustCompile(`^[°a-zA-Z$€£¥Ωμ]+$`)
+
+// DefineUnit adds unit name to e, equal to the value of the RPN expression definition (e.g. "201.168 m")
+// As with prefixed units, the scale is applied to the first unit with a power of ±1, which takes the new name
+func (e *Evaluator) DefineUnit(name, description, definition string) error {
+	if !unitNamePattern.MatchString(name) {
+		return fmt.Errorf("%w: invalid unit name '%s'", ErrDefinition, name)
+	}
+	if _, exists := e.defs.unit(name); exists {
+		return fmt.Errorf("%w: unit '%s' already exists", ErrDefinition, name)
+	}
+	if word, ok := e.defs.reservedWord(name); ok {
+		return fmt.Errorf("%w: unit '%s' conflicts with %s", ErrDefinition, name, word)
+	}
+
+	value, err := e.evalDefinition(definition)
+	if err != nil {
+		return fmt.Errorf("unit '%s': %w", name, err)
+	}
+
+	if value.number.Sign() <= 0 {
+		return fmt.Errorf("%w: unit '%s' must be positive", ErrDefinition, name)
+	}
+
+	newUnit := value.units.clone()
+	for _, dim := range newUnit.dimensions() {
+		unit := newUnit[dim]
+		if count := unit.count(); count != 1 && count != -1 {
+			continue
+		}
+		if unit.factor == nil {
+			return fmt.Errorf("%w: unit '%s' cannot be defined in terms of '%s'", ErrDefinition, name, unit.name)
+		}
+
+		if unit.power == 1 {
+			unit.factor = mul(unit.factor, value.number)
+		} else {
+			unit.factor = div(unit.factor, value.number)
+		}
+		unit.name = name
+		unit.description = description
+		newUnit[dim] = unit
+
+		e.defs.units[name] = newUnit
+		return nil
+	}
+
+	return fmt.Errorf("%w: unit '%s' needs a unit with power 1 in '%s'", ErrDefinition, name, definition)
+}
+
+// DefineBaseUnit adds a new dimension named name (e.g. "request") to e, with name as its base unit,
+// so values in it are checked and converted like those of the built-in dimensions
+func (e *Evaluator) DefineBaseUnit(name, description string) error {
+	if !unitNamePattern.MatchString(name) {
+		return fmt.Errorf("%w: invalid unit name '%s'", ErrDefinition, name)
+	}
+	if _, exists := e.defs.unit(name); exists {
+		return fmt.Errorf("%w: unit '%s' already exists", ErrDefinition, name)
+	}
+	if word, ok := e.defs.reservedWord(name); ok {
+		return fmt.Errorf("%w: unit '%s' conflicts with %s", ErrDefinition, name, word)
+	}
+
+	dim, err := e.defs.registerDimension(name)
+	if err != nil {
+		return err
+	}
+
+	e.defs.units[name] = Unit{dim: UnitPower{BaseUnit{name: name, description: description, dimension: dim, factor: newNumber(1)}, 1}}
+	return nil
+}
+
+var DERIVED_UNIT_NAMES = []string{"J", "N", "Ω", "V", "W", "Pa", "Hz", "lx", "kat", "bps"}
+
+// PreferUnit displays the values of e with the dimensions of units (e.g. "J") in unit name (e.g. "kWh")
+func (e *Evaluator) PreferUnit(units, name string) error {
+	dimensions, ok := e.defs.parseUnits(units)
+	if !ok {
+		return fmt.Errorf("%w: unknown units '%s'", ErrDefinition, units)
+	}
+	preferred, ok := e.defs.parseUnits(name)
+	if !ok {
+		return fmt.Errorf("%w: unknown units '%s'", ErrDefinition, name)
+	}
+	if !unitsMatch(preferred, dimensions) {
+		return fmt.Errorf("%w: '%s' is not in '%s'", ErrIncompatibleUnits, name, units)
+	}
+	if unitScale(preferred, preferred) == nil {
+		return fmt.Errorf("%w: cannot prefer '%s'", ErrDefinition, name)
+	}
+
+	e.defs.preferred[dimensions.signature()] = name
+	return nil
+}
+
+// signature returns the powers of the dimensions of v as a string, the same for units that match
+func (v Unit) signature() string {
+	var powers []string
+	for _, dim := range v.dimensions() {
+		powers = append(powers, fmt.Sprintf("%s^%d", dim, v[dim].power))
+	}
+	return strings.Join(powers, " ")
+}
+
+// 2 sets of units are compatible if they are of the same power in all dimensions;
+// areas and volumes are powers of length, so ha is compatible with m² and l/s with ft³/min
+func (u *Unit) compatible(other Unit) bool {
+	return unitsMatch(*u, other)
+}
+
+// temperatureAdditionValid checks if two temperature units can be added
+func temperatureAdditionValid(left, right Unit) bool {
+	leftTemp := left[Temperature]
+	rightTemp := right[Temperature]
+
+	// If neither has temperature units, not applicable
+	if leftTemp.power == 0 && rightTemp.power == 0 {
+		return true
+	}
+
+	// Both must have the same power
+	if leftTemp.power != rightTemp.power {
+		return false
+	}
+
+	// Valid combinations:
+	// 1. Anything other than readings on °C or °F: K + mK, °R + K, W/°C + W/°F
+	if !isOffsetTemperature(left) && !isOffsetTemperature(right) {
+		return true
+	}
+
+	// 2. Same absolute units: C + C, F + F
+	if leftTemp.name == rightTemp.name {
+		return true
+	}
+
+	// 3. Delta + Absolute: dC + C, dC + F, dF + C, dF + F, dC + K
+	if leftTemp.delta || rightTemp.delta {
+		return true
+	}
+
+	// 4. Different absolute units: C + F, C + K (INVALID)
+	return false
+}
+
+// checks if temperature multiplication is allowed
+func temperatureMultiplicationValid(left, right Unit) bool {
+	// As long as one side does not have temperature units, multiplication is allowed (e.g., 2 * 20°C)
+	if left[Temperature].power == 0 || right[Temperature].power == 0 {
+		return true
+	}
+
+	// Otherwise neither may be a reading on °C or °F: K * K and W/K * K are allowed, but not °C * °C
+	return !isOffsetTemperature(left) && !isOffsetTemperature(right)
+}
+
+func (u *Unit) empty() bool {
+	result := true
+
+	for _, unit := range *u {
+		if unit.power != 0 {
+			result = false
+			break
+		}
+	}
+
+	return result
+}
+
+func unitUnaryOp(op string, left Value) (Value, error) {
+	switch op {
+	case "r":
+		units := left.units.clone()
+		for i, unit := range units {
+			unit.power *= -1
+			units[i] = unit
+		}
+		left.units = units
+	case "sqrt":
+		return unitPow(left, newRationalNumber(1, 2))
+	case "cbrt":
+		return unitPow(left, newRationalNumber(1, 3))
+	default:
+		return left, fmt.Errorf("unimplemented units unary op: '%s'", op)
+	}
+
+	return left, nil
+}
+
+func unitBinaryOp(op string, left, right Value) (Value, error) {
+	units := left.units.clone()
+
+	switch op {
+	case "*", ".", DOT:
+		for _, i := range dimensionsOf(left.units, right.units) {
+			if unit := units[i]; unit.power == 0 {
+				units[i] = right.units[i]
+			} else {
+				unit.power += right.units[i].power
+				units[i] = unit
+			}
+		}
+	case "**", "pow":
+		return unitPow(left, right.number)
+	case "nroot":
+		if right.number.isZero() {
+			return left, fmt.Errorf("%w: no zeroth root", ErrDomain)
+		}
+		return unitPow(left, div(newNumber(1), right.number))
+	case "/":
+		for _, i := range dimensionsOf(left.units, right.units) {
+			if unit := units[i]; unit.power == 0 {
+				unit = right.units[i]
+				unit.power = -unit.power
+				units[i] = unit
+			} else {
+				unit.power -= right.units[i].power
+				units[i] = unit
+			}
+		}
+	default:
+		return left, fmt.Errorf("unimplemented units binary op: '%s'", op)
+	}
+
+	left.units = units
+	return left, nil
+}
+
+// unitPow raises the units of v to a rational power: √(m²) is m and (m²/s²)^(3/2) is m³/s³, but the power of
+// every dimension must stay integral, so √m is an error. A part whose new power is not a whole number
+// of its unit is first converted to the SI unit, so √acre is in m
+func unitPow(v Value, exponent *Number) (Value, error) {
+	if v.units.empty() {
+		return v, nil
+	}
+	if exponent.isComplex() {
+		return v, fmt.Errorf("%w: cannot raise '%s' to a complex power", ErrDomain, v.units.Name())
+	}
+
+	num, den := exponent.Rat.Num(), exponent.Rat.Denom()
+	powers := map[Dimension]int{}
+	for _, dim := range v.units.dimensions() {
+		power := new(big.Int).Mul(big.NewInt(int64(v.units[dim].power)), num)
+		quotient, remainder := new(big.Int).QuoRem(power, den, new(big.Int))
+		if remainder.Sign() != 0 || !quotient.IsInt64() {
+			return v, fmt.Errorf("%w: cannot raise '%s' to the power %s, as %s would have a fractional power",
+				ErrIncompatibleUnits, v.units.Name(), exponent.Rat.RatString(), dim)
+		}
+		powers[dim] = int(quotient.Int64())
+	}
+
+	for dim, power := range powers {
+		unit := v.units[dim]
+		if unit.fits(power) {
+			continue
+		}
+		symbol, ok := coherentUnits[dim]
+		if !ok || unit.factor == nil {
+			return v, fmt.Errorf("%w: cannot raise '%s' to the power %s", ErrIncompatibleUnits, v.units.Name(), exponent.Rat.RatString())
+		}
+		var err error
+		if v, err = v.convertTo(Unit{dim: {UNITS[symbol][dim].BaseUnit, 1}}); err != nil {
+			return v, err
+		}
+	}
+
+	units := Unit{}
+	for dim, power := range powers {
+		if power != 0 {
+			units[dim] = UnitPower{v.units[dim].BaseUnit, power}
+		}
+	}
+	v.units = units
+	return v, nil
+}
+
+// fromSuperscript converts superscript Unicode to regular numbers
+func fromSuperscript(s string) string {
+	superscriptMap := map[rune]rune{
+		'⁰': '0', '¹': '1', '²': '2', '³': '3', '⁴': '4',
+		'⁵': '5', '⁶': '6', '⁷': '7', '⁸': '8', '⁹': '9',
+		'⁻': '-',
+	}
+
+	result := ""
+	for _, r := range s {
+		if normal, exists := superscriptMap[r]; exists {
+			result += string(normal)
+		} else {
+			result += string(r)
+		}
+	}
+	return result
+}
+
+// parseUnits parses units such as m/s², kg·m or ft+in, with the units of d
+// Every unit after a '/' is in the denominator, which may also be grouped or divided again: J/kg·K, J/(kg·K) and J/kg/K
+func (d *definitions) parseUnits(input string) (Unit, bool) {
+	units := Unit{}
+
+	if input == "num" { // remove units
+		return units, true
+	}
+	if input == "" {
+		return nil, false
+	}
+	if strings.Contains(input, compositeSeparator) { // ft+in
+		return parseComposite(input)
+	}
+
+	sepRe := regexp.MustCompile(`(^[.*·/])`)
+	// Updated regex to handle superscripts and negative powers
+	re := regexp.MustCompile(`^([°a-zA-Z$€£¥Ωμ]+)(\^(-?\d+)|([⁰¹²³⁴⁵⁶⁷⁸⁹⁻]+))?`)
+	nextPosition := 0
+	factor := 1
+	grouped := false // within the parentheses of a denominator
+
+	if rune(input[0]) == '/' && len(input) > 1 { // no numerator
+		nextPosition = 1
+		factor = -1
+	}
+
+	for {
+		if factor == -1 && !grouped && strings.HasPrefix(input[nextPosition:], "(") {
+			grouped = true
+			nextPosition++
+		}
+		match := re.FindStringSubmatch(input[nextPosition:])
+		if match == nil {
+			break
+		}
+
+		var power int = 1
+		var err error
+
+		if match[3] != "" {
+			// Handle ^-digit or ^digit format
+			power, err = strconv.Atoi(match[3])
+			if err != nil {
+				break
+			}
+		} else if match[4] != "" {
+			// Handle superscript format
+			normalizedPower := fromSuperscript(match[4])
+			power, err = strconv.Atoi(normalizedPower)
+			if err != nil {
+				break
+			}
+		}
+
+		unitName := match[1]
+
+		// Handle units - all units (base and derived) are in UNITS table
+		if unitUnit, ok := d.unit(unitName); ok {
+			// Handle regular units - add all dimensions from the Unit array
+			for dim, unit := range unitUnit {
+				if unit.power == 0 {
+					continue
+				}
+				part := UnitPower{unit.BaseUnit, factor * power * unit.power}
+				if existing := units[dim]; existing.power != 0 && existing.name != unit.name {
+					merged, ok := mergeUnits(existing, part)
+					if !ok {
+						return units, false
+					}
+					units[dim] = merged
+				} else {
+					units[dim] = UnitPower{unit.BaseUnit, existing.power + part.power}
+				}
+			}
+		} else {
+			return units, false
+		}
+
+		nextPosition += len(match[0])
+		closed := false
+		if grouped && strings.HasPrefix(input[nextPosition:], ")") {
+			grouped, closed = false, true
+			nextPosition++
+		}
+		if nextPosition >= len(input) { // end of input
+			break
+		}
+
+		sepMatch := sepRe.FindStringSubmatch(input[nextPosition:])
+		if sepMatch == nil || closed && sepMatch[1] != "/" {
+			break // unexpected char, or units after the parentheses that are not divided (J/(kg)·K)
+		} else {
+			if sepMatch[1] == "/" {
+				if grouped {
+					break // / within parentheses
+				}
+				factor = -1 // a second / divides by more units (J/kg/K)
+			}
+			nextPosition += len(sepMatch[1])
+		}
+	}
+
+	if nextPosition == len(input) && !grouped { // reached end of input
+		return units, true
+	} else {
+		return units, false
+	}
+}
+
+// mergeUnits returns the one unit that is a and b, different units of the same dimension (acre·ft, s²·hr in kWh/hr),
+// named for its reciprocal when the power is negative; false if the powers cancel (m/ft) or either has no factor
+func mergeUnits(a, b UnitPower) (UnitPower, bool) {
+	power := a.power + b.power
+	if power == 0 || a.factor == nil || b.factor == nil {
+		return UnitPower{}, false
+	}
+
+	scale := mul(a.scale(), b.scale())
+	parts := []UnitPower{a, b}
+	if power < 0 {
+		scale = div(newNumber(1), scale)
+		parts = []UnitPower{{a.BaseUnit, -a.power}, {b.BaseUnit, -b.power}}
+	}
+	name := formatPowers(parts, true)
+	return UnitPower{BaseUnit{name: name, description: name, dimension: a.dimension, factor: scale, span: abs(power)}, power}, true
+}
+
+// parseNumberWithUnits parses a number with attached units as one token, e.g. 5kg, 3.5ft or 12V
+// A trailing binary magnitude (K, M, G...) is read as the start of the units when that parses,
+// so 4Mm is 4 megameters while 4M alone stays the number 4·2²⁰
+// A number of each of several units of one family (5ft11in, 5'11") is a value in their composite unit
+func (d *definitions) parseNumberWithUnits(input string) (*Number, Unit, bool) {
+	num, rest := NewFromString(input)
+	if num == nil || rest == "" {
+		return nil, Unit{}, false
+	}
+
+	numeric := input[:len(input)-len(rest)]
+	if magnitude := numeric[len(numeric)-1:]; strings.Contains(MAGNITUDE, magnitude) {
+		if units, ok := d.parseUnits(magnitude + rest); ok {
+			if plain, ok := parseNumber(numeric[:len(numeric)-1]); ok {
+				return plain, units, true
+			}
+		}
+	}
+
+	units, ok := d.parseUnits(rest)
+	if !ok {
+		return parseCompositeValue(input)
+	}
+	if size, ok := units.largestSize(); ok {
+		num = mul(num, size)
+	}
+	return num, units, true
+}
+
+func (v Unit) Name() string {
+	name := v.String()
+	if name == "" {
+		name = "dimensionless"
+	}
+
+	return name
+}
+
+func (v Unit) String() string {
+	opts := DefaultOptions()
+	return v.Format(&opts)
+}
+
+// Format stringifies units, using derived units and superscripts as selected in opts
+// Composite units are named for their smallest unit, as only values in them alone show each unit (see formatComposite)
+func (v Unit) Format(opts *Options) string {
+	v = v.smallestUnits()
+
+	// Skip derived unit matching if --base option is enabled
+	if !opts.Base {
+		if symbol, ok := v.symbol(opts.defs); ok {
+			return symbol
+		}
+
+		// Otherwise derived units with fewer remaining base units (J·A)
+		if simplified, ok := v.simplify(); ok {
+			return formatPowers(simplified, opts.Superscript)
+		}
+	}
+
+	// Use base units only (or if no derived unit matches)
+	var parts []UnitPower
+	for _, dim := range v.dimensions() {
+		parts = append(parts, v[dim])
+	}
+	return formatPowers(parts, opts.Superscript)
+}
+
+// symbol returns the single unit that v is: a preferred unit of d, a unit named for its compound dimensions
+// (psi, kWh, rpm) or a derived unit, possibly prefixed (kW)
+func (v Unit) symbol(d *definitions) (string, bool) {
+	isOne := func(scale *Number) bool { return scale != nil && scale.Cmp(newNumber(1).Rat) == 0 }
+
+	// Preferred units for these dimensions, when v is exactly that unit
+	if name, ok := d.preferredUnit(v); ok {
+		if preferred, _ := d.parseUnits(name); isOne(unitScale(v, preferred)) {
+			return name, true
+		}
+	}
+
+	for _, dim := range v.dimensions() {
+		if v.compound(d, dim) {
+			if named, _ := d.unit(v[dim].name); unitsMatch(v, named) && isOne(unitScale(v, named)) {
+				return v[dim].name, true
+			}
+		}
+	}
+
+	// Try to match with derived units, possibly prefixed (kW) - use DERIVED_UNIT_NAMES
+	for _, symbol := range DERIVED_UNIT_NAMES {
+		if derivedUnit, exists := UNITS[symbol]; exists {
+			if unitsMatch(v, derivedUnit) {
+				if name, ok := prefixedName(d, symbol, unitScale(v, derivedUnit)); ok {
+					return name, true
+				}
+			}
+		}
+	}
+
+	return "", false
+}
+
+// compound reports whether the part of v in dim is named for a unit of d of other dimensions or powers
+// (kWh, psi, rpm), as the scale of those units is kept in one dimension
+func (v Unit) compound(d *definitions, dim Dimension) bool {
+	named, ok := d.unit(v[dim].name)
+	if !ok || v[dim].power == 0 {
+		return false
+	}
+	for i, unit := range named {
+		if i == dim && unit.count() != 1 || i != dim && unit.power != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// merged reports whether the part of v in dim is a product of units that includes the SI unit (s²·hr in kWh/hr),
+// from a derived or compound unit and another unit of the same dimension; acre·ft is a unit in itself
+func (v Unit) merged(d *definitions, dim Dimension) bool {
+	if _, ok := d.unit(v[dim].name); ok || v[dim].power == 0 {
+		return false
+	}
+	parts := strings.FieldsFunc(v[dim].name, func(r rune) bool { return strings.ContainsRune(DOT+"/()⁰¹²³⁴⁵⁶⁷⁸⁹⁻", r) })
+	return slices.Contains(parts, coherentUnits[dim])
+}
+
+// coherentUnits are the SI units of the dimensions that can carry the name of a compound unit
+var coherentUnits = map[Dimension]string{
+	Mass: "kg", Length: "m", Time: "s", Current: "A", Temperature: "K", Amount: "mol", Luminous: "cd", Information: "bit",
+}
+
+// coherent returns v with any part named for a compound unit that v is not, or merged with one (kWh/hr), in SI units,
+// so it displays as J/s (W) rather than kWh·m²/s³; with --base, compound units are never shown
+func (v Unit) coherent(opts *Options) (Unit, bool) {
+	if _, ok := v.symbol(opts.defs); ok && !opts.Base {
+		return v, false
+	}
+
+	units, changed := v.clone(), false
+	for _, dim := range v.dimensions() {
+		if symbol, ok := coherentUnits[dim]; ok && (v.compound(opts.defs, dim) || v.merged(opts.defs, dim)) {
+			units[dim] = UnitPower{UNITS[symbol][dim].BaseUnit, v[dim].power}
+			changed = true
+		}
+	}
+	return units, changed
+}
+
+// formatPowers stringifies units as numerator/denominator, in order
+func formatPowers(units []UnitPower, superscript bool) string {
+	var parts []string
+	denominator := false
+	for _, unit := range units {
+		if unit.power > 0 {
+			parts = append(parts, unit.format(superscript))
+		} else if unit.power < 0 {
+			denominator = true
+		}
+	}
+	result := strings.Join(parts, DOT)
+	if denominator {
+		parts = parts[:0] // clear the parts
+		for _, unit := range units {
+			if unit.power < 0 {
+				parts = append(parts, unit.format(superscript))
+			}
+		}
+		result += "/" + strings.Join(parts, DOT)
+	}
+
+	return result
+}
+
+// simplify rewrites v with one or two derived units (each to the power ±1) and the fewest remaining
+// base units, e.g. kg·m²/s²·A is J/A; the derived units replace only SI base units of v, so the value is unchanged
+// Returns false if no combination is shorter than v in base units
+func (v Unit) simplify() ([]UnitPower, bool) {
+	type term struct {
+		symbol string
+		power  int
+	}
+	var candidates [][]term
+	for i, first := range DERIVED_UNIT_NAMES {
+		for _, p := range []int{1, -1} {
+			candidates = append(candidates, []term{{first, p}})
+			for _, second := range DERIVED_UNIT_NAMES[i+1:] {
+				for _, q := range []int{1, -1} {
+					candidates = append(candidates, []term{{first, p}, {second, q}})
+				}
+			}
+		}
+	}
+
+	// cost is the number of units displayed, then the sum of their powers, then the number divided by:
+	// kg·m/s is N·s, not J·s/m, and kg·m²/s²·A is V·s, not J/A
+	cost := func(terms []term, powers map[Dimension]int) int {
+		parts, total, divided := len(terms), len(terms), 0
+		for _, t := range terms {
+			if t.power < 0 {
+				divided++
+			}
+		}
+		for _, power := range powers {
+			if power != 0 {
+				parts++
+				total += max(power, -power)
+			}
+			if power < 0 {
+				divided++
+			}
+		}
+		return parts*1000 + total*10 + divided
+	}
+
+	powers := func() map[Dimension]int {
+		powers := map[Dimension]int{}
+		for dim, unit := range v {
+			powers[dim] = unit.power
+		}
+		return powers
+	}
+
+	var best []UnitPower
+	// A substitution must display fewer or lower powered units, so m/s is not Hz·m
+	bestCost := cost(nil, powers()) / 10 * 10
+	for _, terms := range candidates {
+		remainder, units := powers(), v.clone()
+		valid := true
+		for _, t := range terms {
+			for dim, unit := range UNITS[t.symbol] {
+				if unit.power == 0 {
+					continue
+				}
+				// v must be in the SI unit of each dimension the derived unit uses (or not use it at all);
+				// a dimension v does not use comes from one derived unit only, so m³/s is not W/Pa
+				if introduced, ok := units[dim]; ok && introduced.power == 0 {
+					valid = false
+				} else if units[dim].power == 0 {
+					units[dim] = UnitPower{unit.BaseUnit, 0}
+				} else if units[dim].factor == nil || units[dim].span != 0 || units[dim].factor.Cmp(unit.factor.Rat) != 0 {
+					valid = false
+				}
+				remainder[dim] -= t.power * unit.power
+			}
+		}
+		// Derived units only take the place of base units of v, so m/s² is not N/kg
+		for dim, power := range remainder {
+			if power != 0 && v[dim].power == 0 {
+				valid = false
+			}
+		}
+		if !valid || cost(terms, remainder) >= bestCost {
+			continue
+		}
+
+		var result []UnitPower
+		for _, t := range terms {
+			result = append(result, UnitPower{BaseUnit{name: t.symbol}, t.power})
+		}
+		for _, dim := range slices.Sorted(maps.Keys(remainder)) {
+			if power := remainder[dim]; power != 0 {
+				result = append(result, UnitPower{units[dim].BaseUnit, power})
+			}
+		}
+		best, bestCost = result, cost(terms, remainder)
+	}
+
+	return best, best != nil
+}
+
+// unitScale returns the size of units in to, which must have the same powers; nil if either has no static factors
+func unitScale(units, to Unit) *Number {
+	scale := newNumber(1)
+	for i := range units {
+		if units[i].power == 0 {
+			continue
+		}
+		if units[i].factor == nil || to[i].factor == nil || !to[i].fits(units[i].power) {
+			return nil
+		}
+		scale = mul(scale, div(units[i].scale(), UnitPower{to[i].BaseUnit, units[i].power}.scale()))
+	}
+	return scale
+}
+
+// prefixedName returns the name of the unit that is scale times unit symbol: the symbol itself for 1,
+// or the symbol with an SI prefix for a power of 10 (if that unit exists in d)
+func prefixedName(d *definitions, symbol string, scale *Number) (string, bool) {
+	if scale == nil {
+		return "", false
+	}
+	if scale.Cmp(newNumber(1).Rat) == 0 {
+		return symbol, true
+	}
+
+	for _, prefix := range SI_PREFIXES {
+		if _, exists := d.unit(prefix.symbol + symbol); exists && scale.Cmp(intPow(newNumber(10), prefix.power).Rat) == 0 {
+			return prefix.symbol + symbol, true
+		}
+	}
+	return "", false
+}
+
+// siBaseName returns the unit of d that takes SI prefixes that name is, with or without one
+func siBaseName(d *definitions, name string) (string, bool) {
+	for _, symbol := range d.prefixedUnits() {
+		if name == symbol {
+			return symbol, true
+		}
+		for _, prefix := range SI_PREFIXES {
+			if name == prefix.symbol+symbol {
+				return symbol, true
+			}
+		}
+	}
+	return "", false
+}
+
+// siUnit returns the unit of d that takes SI prefixes with the dimensions of v, when every part of v
+// is an SI unit (so kg·m²/s³ is W, but lb·ft²/s³ is not)
+func (v Unit) siUnit(d *definitions) (string, bool) {
+	if v.empty() {
+		return "", false
+	}
+	for _, unit := range v {
+		if _, ok := siBaseName(d, unit.name); unit.power != 0 && !ok {
+			return "", false
+		}
+	}
+
+	// The unit v is already in, when there is a choice (lm rather than cd)
+	for _, unit := range v {
+		if symbol, ok := siBaseName(d, unit.name); ok && unit.power != 0 {
+			if named, _ := d.unit(symbol); unitsMatch(v, named) {
+				return symbol, true
+			}
+		}
+	}
+	// Otherwise units of one power of each dimension (m³ stays m³, not kl)
+	for _, symbol := range d.prefixedUnits() {
+		if named, _ := d.unit(symbol); unitsMatch(v, named) && named.linear() {
+			return symbol, true
+		}
+	}
+	return "", false
+}
+
+// linear reports whether each part of v is a unit of one power of its dimension (m³, but not l)
+func (v Unit) linear() bool {
+	for _, unit := range v {
+		if unit.span != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// unitsMatch checks if two Unit are equivalent
+func unitsMatch(units1, units2 Unit) bool {
+	for _, i := range dimensionsOf(units1, units2) {
+		if units1[i].power != units2[i].power {
+			return false
+		}
+	}
+	return true
+}
+
+// toSuperscript converts a number to its superscript Unicode representation
+func toSuperscript(n int) string {
+	if n == 0 {
+		return "⁰"
+	}
+	if n < 0 {
+		return "⁻" + toSuperscript(-n)
+	}
+
+	superscripts := map[rune]rune{
+		'0': '⁰', '1': '¹', '2': '²', '3': '³', '4': '⁴',
+		'5': '⁵', '6': '⁶', '7': '⁷', '8': '⁸', '9': '⁹',
+	}
+
+	numStr := fmt.Sprintf("%d", n)
+	result := ""
+	for _, digit := range numStr {
+		if superscript, exists := superscripts[digit]; exists {
+			result += string(superscript)
+		}
+	}
+	return result
+}
+
+// should be used from Unit.Format; stringifies with absolute value of power (in units of u, so acre² not acre⁴)
+func (u UnitPower) format(superscript bool) string {
+	absPower := abs(u.count())
+	if absPower == 1 {
+		return u.name
+	}
+
+	// Products of units (acre·ft) are one unit
+	name := u.name
+	if strings.ContainsAny(name, DOT+"/") {
+		name = "(" + name + ")"
+	}
+
+	// Use superscript by default, unless -S option is specified
+	if superscript {
+		return name + toSuperscript(absPower)
+	} else {
+		return fmt.Sprintf("%s^%d", name, absPower)
+	}
+}
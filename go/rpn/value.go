@@ -0,0 +1,493 @@
+// Copyright 2024 Mike Carlton
+// Released under terms of the MIT License:
+//   http://www.opensource.org/licenses/mit-license.php
+
+package rpn
+
+import (
+	"fmt"
+	"math/big"
+)
+
+type Value struct {
+	number *Number
+	units  Unit
+}
+
+// NewValue returns a Value of number in units
+func NewValue(number *Number, units Unit) Value {
+	return Value{number: number, units: units}
+}
+
+// Number returns the numeric part of the value, in its units
+func (v Value) Number() *Number {
+	return v.number
+}
+
+// Units returns the units of the value
+func (v Value) Units() Unit {
+	return v.units
+}
+
+type Operator struct {
+	exec           NumericOp
+	multiplicative bool
+	unary          bool
+	dimensionless  bool
+	integerOnly    bool
+	angle          bool // argument is an angle, converted to radians; plain numbers are radians
+	angleResult    bool // result is an angle in radians
+	complex        bool // accepts complex arguments
+}
+
+// OPALIAS are the built-in names for operators; Evaluator.AliasOperator adds more
+var OPALIAS = Aliases{
+	".":   "*",
+	"•":   "*",
+	"pow": "**",
+}
+
+// exact adapts arithmetic that cannot fail to a NumericOp
+func exact(op func(x, y *Number) *Number) NumericOp {
+	return func(x, y *Number, p precision) (*Number, error) {
+		return op(x, y), nil
+	}
+}
+
+var OPERATOR = map[string]Operator{
+	"+":     {exec: exact(add), complex: true},
+	"-":     {exec: exact(sub), complex: true},
+	"*":     {exec: exact(mul), multiplicative: true, complex: true},
+	"/":     {exec: quotient, multiplicative: true, complex: true},
+	"%":     {exec: mod, dimensionless: true},
+	"**":    {exec: pow, multiplicative: true, dimensionless: true, complex: true},
+	"chs":   {exec: neg, unary: true, complex: true},
+	"t":     {exec: truncate, unary: true},
+	"!":     {exec: factorial, unary: true},
+	"r":     {exec: reciprocal, multiplicative: true, unary: true, complex: true},
+	"log":   {exec: log, dimensionless: true, unary: true, complex: true},
+	"log10": {exec: log10, dimensionless: true, unary: true, complex: true},
+	"log2":  {exec: log2, dimensionless: true, unary: true, complex: true},
+	"sqrt":  {exec: sqrt, multiplicative: true, unary: true, complex: true},
+	"cbrt":  {exec: cbrt, multiplicative: true, unary: true, complex: true},
+	"nroot": {exec: nroot, multiplicative: true, dimensionless: true, complex: true},
+	"rand":  {exec: random, dimensionless: true, unary: true},
+	"mask":  {exec: mask, dimensionless: true, unary: true, integerOnly: true},
+
+	// Trigonometric and hyperbolic functions
+	"sin":   {exec: sin, unary: true, angle: true},
+	"cos":   {exec: cos, unary: true, angle: true},
+	"tan":   {exec: tan, unary: true, angle: true},
+	"asin":  {exec: asin, dimensionless: true, unary: true, angleResult: true},
+	"acos":  {exec: acos, dimensionless: true, unary: true, angleResult: true},
+	"atan":  {exec: atan, dimensionless: true, unary: true, angleResult: true},
+	"atan2": {exec: atan2, angleResult: true},
+	"sinh":  {exec: sinh, dimensionless: true, unary: true},
+	"cosh":  {exec: cosh, dimensionless: true, unary: true},
+	"tanh":  {exec: tanh, dimensionless: true, unary: true},
+	"asinh": {exec: asinh, dimensionless: true, unary: true},
+	"acosh": {exec: acosh, dimensionless: true, unary: true},
+	"atanh": {exec: atanh, dimensionless: true, unary: true},
+
+	// Complex operations
+	"abs":  {exec: absolute, unary: true, complex: true},
+	"arg":  {exec: argument, unary: true, complex: true, angleResult: true},
+	"conj": {exec: conjugate, unary: true, complex: true},
+	"re":   {exec: realPart, unary: true, complex: true},
+	"im":   {exec: imaginaryPart, unary: true, complex: true},
+
+	// Bitwise operations (integers only)
+	"&":  {exec: bitwiseAnd, dimensionless: true, integerOnly: true},
+	"|":  {exec: bitwiseOr, dimensionless: true, integerOnly: true},
+	"^":  {exec: bitwiseXor, dimensionless: true, integerOnly: true},
+	"<<": {exec: leftShift, dimensionless: true, integerOnly: true},
+	">>": {exec: rightShift, dimensionless: true, integerOnly: true},
+	"~":  {exec: bitwiseNot, dimensionless: true, integerOnly: true, unary: true},
+}
+
+func (v Value) binaryOp(op string, other Value, p precision) (Value, error) {
+	if !OPERATOR[op].complex && (v.number.isComplex() || other.number.isComplex()) {
+		return v, fmt.Errorf("%w: '%s' does not accept complex values", ErrDomain, op)
+	}
+	if OPERATOR[op].integerOnly && (!v.number.isIntegral() || !other.number.isIntegral()) {
+		return v, fmt.Errorf("%w for '%s'", ErrNotInteger, op)
+	}
+
+	if OPERATOR[op].dimensionless && !other.units.empty() {
+		return v, fmt.Errorf("%w for '%s', got '%s'", ErrDimensionless, op, other)
+	}
+
+	var err error
+	if OPERATOR[op].multiplicative {
+		// For multiplication/division with temperatures, check special rules
+		if (op == "*" || op == "**" || op == "pow") && !temperatureMultiplicationValid(v.units, other.units) {
+			return v, fmt.Errorf("%w: cannot multiply temperatures %s %s %s", ErrIncompatibleUnits, v.units, op, other.units)
+		}
+		if op == "/" && v.units[Temperature].power != 0 && other.units[Temperature].power != 0 &&
+			(isOffsetTemperature(v.units) || isOffsetTemperature(other.units)) {
+			// Readings on °C or °F have no meaningful ratio, so they divide as read: 100°C / 50°F is 2
+			other.units = other.units.clone()
+			other.units[Temperature] = UnitPower{v.units[Temperature].BaseUnit, other.units[Temperature].power}
+		}
+		if v, other, err = shareUnits(v, other); err != nil {
+			return v, err
+		}
+		if other, err = other.convertTo(v.units); err != nil {
+			return v, err
+		}
+		if v, err = unitBinaryOp(op, v, other); err != nil {
+			return v, err
+		}
+	} else {
+		if v.units.compatible(other.units) {
+			// For addition/subtraction with temperatures, check special rules
+			if (op == "+" || op == "-") && !temperatureAdditionValid(v.units, other.units) {
+				return v, fmt.Errorf("%w: invalid temperature operation %s %s %s", ErrIncompatibleUnits, v.units, op, other.units)
+			}
+		} else {
+			return v, fmt.Errorf("%w for '%s': %s vs %s", ErrIncompatibleUnits, op, v.units.Name(), other.units.Name())
+		}
+		if other, err = other.convertTo(v.units); err != nil {
+			return v, err
+		}
+	}
+
+	if OPERATOR[op].angleResult {
+		p += angleDigits
+	}
+	number, err := OPERATOR[op].exec(v.number, other.number, p)
+	if err != nil {
+		return v, err
+	}
+	v.number = number
+	if OPERATOR[op].angleResult {
+		v.units = UNITS["rad"]
+	}
+	return v, nil
+}
+
+func (v Value) unaryOp(op string, p precision) (Value, error) {
+	if !OPERATOR[op].complex && v.number.isComplex() {
+		return v, fmt.Errorf("%w: '%s' does not accept complex values", ErrDomain, op)
+	}
+	if OPERATOR[op].integerOnly && !v.number.isIntegral() {
+		return v, fmt.Errorf("%w for '%s'", ErrNotInteger, op)
+	}
+	if OPERATOR[op].dimensionless && !v.units.empty() {
+		return v, fmt.Errorf("%w for '%s', got '%s'", ErrDimensionless, op, v)
+	} else if OPERATOR[op].angle && !v.units.empty() {
+		radians := UNITS["rad"]
+		if !v.units.compatible(radians) {
+			return v, fmt.Errorf("%w for '%s': angle required, got '%s'", ErrIncompatibleUnits, op, v.units.Name())
+		}
+		var err error
+		if v, err = v.convertTo(radians); err != nil {
+			return v, err
+		}
+		v.units = Unit{}
+	} else if OPERATOR[op].multiplicative {
+		var err error
+		if v, err = unitUnaryOp(op, v); err != nil {
+			return v, err
+		}
+	}
+
+	if OPERATOR[op].angleResult {
+		p += angleDigits
+	}
+	number, err := OPERATOR[op].exec(v.number, nil, p)
+	if err != nil {
+		return v, err
+	}
+	v.number = number
+	if OPERATOR[op].angleResult {
+		v.units = UNITS["rad"]
+	}
+	return v, nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	} else {
+		return n
+	}
+}
+
+// converts v to units
+// when adding or subtracting, there must first be a check that the units are compatible (i.e. same power on all dimensions)
+// when multiplying or dividing, units are converted to the new units
+// will never remove units from value, and leaves a dimension whose power is not a whole number of the new unit (ft to acre)
+func (v Value) convertTo(units Unit) (Value, error) {
+	v.units = v.units.clone()
+
+	for dim, unit := range units {
+		from := v.units[dim]
+		if unit.power == 0 || from.power == 0 || !unit.fits(from.power) {
+			// do nothing
+		} else {
+			to := UnitPower{unit.BaseUnit, from.power}
+			if from.factor != nil && unit.factor != nil {
+				// Both units use static factors - standard scaling conversion
+				v.number = mul(v.number, div(from.scale(), to.scale()))
+			} else {
+				// At least one unit uses dynamic conversion
+				number, err := dynamicConvert(v.number, v.units, dim, unit.BaseUnit)
+				if err != nil {
+					return v, err
+				}
+				v.number = number
+			}
+			v.units[dim] = to
+		}
+	}
+
+	return v, nil
+}
+
+// shareUnits converts the parts of v and other that cannot be in the same unit (acre·ft, l/m)
+// to a unit of one power of their dimension: the one of either value, or the SI unit
+func shareUnits(v, other Value) (Value, Value, error) {
+	for _, dim := range dimensionsOf(v.units, other.units) {
+		left, right := v.units[dim], other.units[dim]
+		if left.power == 0 || right.power == 0 || left.fits(right.power) || left.factor == nil || right.factor == nil {
+			continue
+		}
+
+		var linear BaseUnit
+		if right.fits(1) {
+			linear = right.BaseUnit
+		} else if left.fits(1) {
+			linear = left.BaseUnit
+		} else if symbol, ok := coherentUnits[dim]; ok {
+			linear = UNITS[symbol][dim].BaseUnit
+		} else {
+			return v, other, fmt.Errorf("%w: cannot combine %s and %s", ErrIncompatibleUnits, left.name, right.name)
+		}
+
+		var err error
+		if v, err = v.convertTo(Unit{dim: {linear, 1}}); err != nil {
+			return v, other, err
+		}
+		if other, err = other.convertTo(Unit{dim: {linear, 1}}); err != nil {
+			return v, other, err
+		}
+	}
+	return v, other, nil
+}
+
+func (v Value) apply(units Unit) (Value, error) {
+	if v.units.empty() || units.empty() {
+		v.units = units
+	} else if v.units.compatible(units) {
+		for i, unit := range units {
+			if unit.power == 0 || (unit.name == v.units[i].name && unit.power == v.units[i].power) {
+				continue
+			}
+			// Use factor for simple scaling, or factorFunction for dynamic conversion
+			if v.units[i].factor != nil && unit.factor != nil {
+				// Both units use static factors - standard scaling conversion
+				v.number = mul(v.number, div(v.units[i].scale(), unit.scale()))
+			} else {
+				// At least one unit uses dynamic conversion
+				number, err := dynamicConvert(v.number, v.units, i, unit.BaseUnit)
+				if err != nil {
+					return v, err
+				}
+				v.number = number
+			}
+		}
+		v.units = units
+	} else {
+		return v, fmt.Errorf("%w: %s vs %s", ErrIncompatibleUnits, v.units.Name(), units.Name())
+	}
+
+	return v, nil
+}
+
+func (v Value) String() string {
+	opts := DefaultOptions()
+	return v.Format(&opts)
+}
+
+// Format stringifies a value with the precision and unit style selected in opts
+func (v Value) Format(opts *Options) string {
+	v = v.display(opts)
+
+	// Check if this is a time unit that should be displayed in time format
+	if v.units[Time].power == 1 && v.isOnlyTimeUnit() && !v.number.isComplex() {
+		if v.units[Time].name == "hr" {
+			return v.formatAsHours()
+		} else if v.units[Time].name == "min" {
+			return v.formatAsMinutes()
+		}
+	}
+	if composite, ok := v.formatComposite(opts); ok {
+		return composite
+	}
+
+	var result string
+	if opts.ShowRational {
+		result = fmt.Sprintf("%s (%s)", v.number.formatWith(opts), toRational(v.number))
+	} else {
+		result = v.number.formatWith(opts)
+	}
+	units := v.units.Format(opts)
+
+	if units != "" {
+		result += " " + units
+	}
+	return result
+}
+
+// display returns v converted to any preferred unit, with parts named for other compound units (kWh/hr)
+// in SI units, and, with AutoPrefix, rescaled to an SI prefix
+func (v Value) display(opts *Options) Value {
+	if name, ok := opts.defs.preferredUnit(v.units); ok && !opts.Base {
+		if units, ok := opts.defs.parseUnits(name); ok {
+			if result, err := v.apply(units); err == nil {
+				v = result
+			}
+		}
+	}
+	if units, ok := v.units.coherent(opts); ok {
+		if result, err := v.apply(units); err == nil {
+			v = result
+		}
+	}
+	if opts.AutoPrefix {
+		v = v.autoPrefix(opts.defs)
+	}
+	return v
+}
+
+// autoPrefix rescales a value in a single SI unit of d, possibly prefixed or derived (e.g. mA or kW),
+// to the SI prefix that puts the mantissa in [1, 1000): 0.000047 A is 47 μA
+func (v Value) autoPrefix(d *definitions) Value {
+	symbol, ok := v.units.siUnit(d)
+	if !ok || v.number.isZero() || v.number.isComplex() {
+		return v
+	}
+	unit, _ := d.unit(symbol)
+	base, err := v.apply(unit)
+	if err != nil {
+		return v
+	}
+
+	size := new(big.Rat).Abs(base.number.Rat)
+	power := 18
+	for power > -18 && size.Cmp(intPow(newNumber(10), power).Rat) < 0 {
+		power -= 3
+	}
+	if power == 0 {
+		return base
+	}
+
+	for _, prefix := range SI_PREFIXES {
+		if prefix.power == power {
+			if units, exists := d.unit(prefix.symbol + symbol); exists {
+				if result, err := base.apply(units); err == nil {
+					return result
+				}
+			}
+			break
+		}
+	}
+	return base
+}
+
+// isOnlyTimeUnit checks if this value only has time units (no other dimensions)
+func (v Value) isOnlyTimeUnit() bool {
+	for i, unit := range v.units {
+		if i == Time {
+			continue // Skip time dimension
+		}
+		if unit.power != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// formatAsHours formats time value in hr units as H:MM:SS
+func (v Value) formatAsHours() string {
+	// Convert to seconds for calculation
+	totalSecondsNum := mul(v.number, newNumber(3600))
+	totalSeconds, _ := totalSecondsNum.Rat.Float64()
+
+	hours := int(totalSeconds) / 3600
+	minutes := (int(totalSeconds) % 3600) / 60
+	seconds := int(totalSeconds) % 60
+
+	// Handle fractional seconds
+	fractionalSeconds := totalSeconds - float64(int(totalSeconds))
+	if fractionalSeconds > 0 {
+		return fmt.Sprintf("%d:%02d:%02d%s hr", hours, minutes, seconds, formatFraction(fractionalSeconds))
+	}
+	return fmt.Sprintf("%d:%02d:%02d hr", hours, minutes, seconds)
+}
+
+// formatAsMinutes formats time value in mn units as M:SS
+func (v Value) formatAsMinutes() string {
+	// Convert to seconds for calculation
+	totalSecondsNum := mul(v.number, newNumber(60))
+	totalSeconds, _ := totalSecondsNum.Rat.Float64()
+
+	minutes := int(totalSeconds) / 60
+	seconds := int(totalSeconds) % 60
+
+	// Handle fractional seconds
+	fractionalSeconds := totalSeconds - float64(int(totalSeconds))
+	if fractionalSeconds > 0 {
+		return fmt.Sprintf("%d:%02d%s min", minutes, seconds, formatFraction(fractionalSeconds))
+	}
+	return fmt.Sprintf("%d:%02d min", minutes, seconds)
+}
+
+// formatTimeAsHours formats just the time number part in hr units as H:MM:SS (no units suffix)
+func (v Value) formatTimeAsHours() string {
+	// Convert to seconds for calculation
+	totalSecondsNum := mul(v.number, newNumber(3600))
+	totalSeconds, _ := totalSecondsNum.Rat.Float64()
+
+	hours := int(totalSeconds) / 3600
+	minutes := (int(totalSeconds) % 3600) / 60
+	seconds := int(totalSeconds) % 60
+
+	// Handle fractional seconds
+	fractionalSeconds := totalSeconds - float64(int(totalSeconds))
+	if fractionalSeconds > 0 {
+		return fmt.Sprintf("%d:%02d:%02d%s", hours, minutes, seconds, formatFraction(fractionalSeconds))
+	}
+	return fmt.Sprintf("%d:%02d:%02d", hours, minutes, seconds)
+}
+
+// formatTimeAsMinutes formats just the time number part in min units as M:SS (no units suffix)
+func (v Value) formatTimeAsMinutes() string {
+	// Convert to seconds for calculation
+	totalSecondsNum := mul(v.number, newNumber(60))
+	totalSeconds, _ := totalSecondsNum.Rat.Float64()
+
+	minutes := int(totalSeconds) / 60
+	seconds := int(totalSeconds) % 60
+
+	// Handle fractional seconds
+	fractionalSeconds := totalSeconds - float64(int(totalSeconds))
+	if fractionalSeconds > 0 {
+		return fmt.Sprintf("%d:%02d%s", minutes, seconds, formatFraction(fractionalSeconds))
+	}
+	return fmt.Sprintf("%d:%02d", minutes, seconds)
+}
+
+// formatFraction formats fractional part of seconds (e.g., ".25" for 0.25)
+func formatFraction(frac float64) string {
+	if frac == 0 {
+		return ""
+	}
+	// Format with appropriate precision, removing leading zero
+	formatted := fmt.Sprintf("%.2f", frac)
+	if formatted[0] == '0' {
+		return formatted[1:] // Remove leading '0' to get just ".xx"
+	}
+	return formatted
+}
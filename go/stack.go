@@ -1,553 +0,0 @@
-// Copyright 2024 Mike Carlton
-// Released under terms of the MIT License:
-//   http://www.opensource.org/licenses/mit-license.php
-
-package main
-
-import (
-	"fmt"
-	"strings"
-)
-
-type Stack struct {
-	values []Value
-}
-
-func newStack() *Stack {
-	return &Stack{values: []Value{}}
-}
-
-var STACKALIAS = Aliases{
-	"dup": "d",
-	"pop": "p",
-}
-
-var STACKOP = map[string]func(*Stack){
-	"x": func(s *Stack) { s.exchange() },
-	"d": func(s *Stack) { s.dup() },
-	"p": func(s *Stack) {
-		if _, err := s.pop(); err != nil {
-			die("Stack is empty for '%s', exiting", "pop")
-		}
-	},
-	"mini":  func(s *Stack) { s.min(false) },
-	"mini!": func(s *Stack) { s.min(true) },
-	"max":   func(s *Stack) { s.max(false) },
-	"max!":  func(s *Stack) { s.max(true) },
-	"mean":  func(s *Stack) { s.mean(false) },
-	"mean!": func(s *Stack) { s.mean(true) },
-	"size":  func(s *Stack) { s.stackSize(false) },
-	"size!": func(s *Stack) { s.stackSize(true) },
-}
-
-func (s *Stack) binaryOp(op string) {
-	right, _ := s.pop()
-	left, err := s.pop()
-	if err != nil {
-		die("Not enough arguments for binary operation '%s', exiting", op)
-	}
-
-	s.push(left.binaryOp(op, right))
-}
-
-func (s *Stack) unaryOp(op string) {
-	value, err := s.pop()
-	if err != nil {
-		die("Not enough arguments for unary operation '%s', exiting", op)
-	}
-
-	s.push(value.unaryOp(op))
-}
-
-func (s *Stack) apply(units Unit) {
-	value, err := s.pop()
-	if err != nil {
-		die("Not enough arguments for '%s', exiting", units)
-	}
-
-	s.push(value.apply(units))
-}
-
-func (s *Stack) reduce(op string) {
-	if len(s.values) < 2 {
-		die("Not enough arguments for reduction operation '@%s', exiting", op)
-	}
-
-	// Reduce all values on the stack using the given operation
-	// Start with the bottom value and apply the operation left-to-right
-	result := s.values[0]
-	for i := 1; i < len(s.values); i++ {
-		result = result.binaryOp(op, s.values[i])
-	}
-
-	// Clear the stack and push the result
-	s.values = []Value{result}
-}
-
-func (s *Stack) push(v Value) {
-	s.values = append(s.values, v)
-}
-
-func (s *Stack) pop() (Value, error) {
-	if len(s.values) == 0 {
-		return Value{}, fmt.Errorf("stack is empty")
-	}
-	v := s.values[len(s.values)-1]
-	s.values = s.values[:len(s.values)-1]
-
-	return v, nil
-}
-
-func (s *Stack) peek() (Value, error) {
-	if len(s.values) == 0 {
-		return Value{}, fmt.Errorf("stack is empty")
-	}
-
-	return s.values[len(s.values)-1], nil
-}
-
-func (s *Stack) dup() {
-	if len(s.values) < 1 {
-		die("Stack is empty for '%s', exiting", "duplicate")
-	}
-
-	// TODO: need to copy value, otherwise they're aliased
-	s.values = append(s.values, s.values[len(s.values)-1])
-}
-
-func (s *Stack) exchange() {
-	if len(s.values) < 2 {
-		die("Not enough arguments for '%s', exiting", "exchange")
-	}
-
-	s.values[len(s.values)-1], s.values[len(s.values)-2] = s.values[len(s.values)-2], s.values[len(s.values)-1]
-}
-
-func (s *Stack) size() int {
-	return len(s.values)
-}
-
-func (s *Stack) oneline() string {
-	var sb strings.Builder
-	separator := ""
-	for i, v := range s.values {
-		sb.WriteString(fmt.Sprintf("%s%s", separator, v))
-		if i == 0 {
-			separator = " "
-		}
-	}
-	return sb.String()
-}
-
-// ColumnWidths tracks integer and fractional part widths for alignment
-type ColumnWidths struct {
-	integerWidth    int // width of integer part (before decimal point)
-	fractionalWidth int // width of fractional part (including decimal point)
-}
-
-// return max widths for all enabled base columns, separating integer and fractional parts
-func maxWidths(values []Value) map[int]ColumnWidths {
-	widths := make(map[int]ColumnWidths)
-	bases := getEnabledBases()
-
-	for _, base := range bases {
-		maxIntWidth := 0
-		maxFracWidth := 0
-
-		for _, value := range values {
-			// Skip this base if not applicable to this value type
-			if base != 10 && !value.number.isIntegral() {
-				if base != 16 || !options.showHexFloat {
-					continue
-				}
-			}
-
-			str := toString(value.number, base)
-			intPart, fracPart := splitNumber(str)
-
-			if len(intPart) > maxIntWidth {
-				maxIntWidth = len(intPart)
-			}
-			if len(fracPart) > maxFracWidth {
-				maxFracWidth = len(fracPart)
-			}
-		}
-
-		widths[base] = ColumnWidths{
-			integerWidth:    maxIntWidth,
-			fractionalWidth: maxFracWidth,
-		}
-	}
-
-	return widths
-}
-
-// splitNumber splits a number string into integer and fractional parts
-// For hex floats like "0x1.92p+06", splits at the decimal point
-// For regular decimals like "100.5", splits at the decimal point
-// Returns (integerPart, fractionalPart) where fractionalPart includes the decimal point
-func splitNumber(str string) (string, string) {
-	// Handle hex floating point format (e.g., "0x1.92p+06")
-	if strings.HasPrefix(str, "0x") && strings.Contains(str, ".") {
-		parts := strings.SplitN(str, ".", 2)
-		return parts[0], "." + parts[1]
-	}
-	// Handle regular decimal format
-	if strings.Contains(str, ".") {
-		parts := strings.SplitN(str, ".", 2)
-		return parts[0], "." + parts[1]
-	}
-	// Integer - no fractional part
-	return str, ""
-}
-
-// return list of bases to display based on command-line flags
-func getEnabledBases() []int {
-	bases := []int{10} // Always show decimal
-	if options.showHex {
-		bases = append(bases, 16)
-	}
-	if options.showBinary {
-		bases = append(bases, 2)
-	}
-	if options.showOctal {
-		bases = append(bases, 8)
-	}
-	return bases
-}
-
-// return list of special formats to display based on command-line flags
-func getEnabledFormats() []string {
-	var formats []string
-	if options.showRational {
-		formats = append(formats, "rational")
-	}
-	if options.showIEEE32 {
-		formats = append(formats, "ieee32")
-	}
-	if options.showIEEE64 {
-		formats = append(formats, "ieee64")
-	}
-	if options.showIPv4 {
-		formats = append(formats, "ipv4")
-	}
-	if options.showFactor {
-		formats = append(formats, "factor")
-	}
-	return formats
-}
-
-// maxRationalWidth returns the max string width of rational representations across all values
-func maxRationalWidth(values []Value) int {
-	max := 0
-	for _, value := range values {
-		w := len(value.number.Rat.Num().String()) + 1 + len(value.number.Rat.Denom().String())
-		if w > max {
-			max = w
-		}
-	}
-	return max
-}
-
-// maxIPv4Width returns the max string width of IPv4 representations across all values
-func maxIPv4Width(values []Value) int {
-	max := 0
-	for _, value := range values {
-		if value.number.isIntegral() {
-			if s := toIPv4(value.number); s != "" {
-				if len(s) > max {
-					max = len(s)
-				}
-			}
-		}
-	}
-	return max
-}
-
-func (s *Stack) print() {
-	widths := maxWidths(s.values)
-	bases := getEnabledBases()
-	formats := getEnabledFormats()
-
-	var rationalWidth int
-	if options.showRational {
-		rationalWidth = maxRationalWidth(s.values)
-	}
-	var ipv4Width int
-	if options.showIPv4 {
-		ipv4Width = maxIPv4Width(s.values)
-	}
-
-	for i := len(s.values) - 1; i >= 0; i-- {
-		value := s.values[i]
-		separator := ""
-
-		// Check if this has a time unit that should be displayed in time format
-		hasTimeUnit := value.units[Time].power == 1 && (value.units[Time].name == "hr" || value.units[Time].name == "min")
-
-		if hasTimeUnit {
-			// Format the number part as time
-			timeNumStr := ""
-			if value.units[Time].name == "hr" {
-				timeNumStr = value.formatTimeAsHours()
-			} else if value.units[Time].name == "min" {
-				timeNumStr = value.formatTimeAsMinutes()
-			}
-
-			// Print the formatted time number
-			fmt.Printf("%s", timeNumStr)
-
-			// Add units if present
-			if !value.units.empty() {
-				fmt.Printf(" %s", value.units.String())
-			}
-		} else {
-			// Print each enabled base (normal logic)
-			for _, base := range bases {
-				// Skip binary and octal for non-integral numbers
-				// For hex, skip non-integral numbers unless showHexFloat is enabled
-				if base != 10 && !value.number.isIntegral() {
-					if base != 16 || !options.showHexFloat {
-						continue
-					}
-				}
-
-				str := toString(value.number, base)
-				intPart, fracPart := splitNumber(str)
-				colWidth := widths[base]
-
-				// Print with units digit alignment: right-align integer part, left-align fractional part
-				fmt.Printf("%s%*s%s", separator, colWidth.integerWidth, intPart, fracPart)
-
-				// Pad fractional part to maintain column alignment
-				padding := colWidth.fractionalWidth - len(fracPart)
-				if padding > 0 {
-					fmt.Printf("%*s", padding, "")
-				}
-
-				separator = "  " // Two spaces between columns
-			}
-
-			// Print special formats (IPv4, rational, factor, etc.)
-			for _, format := range formats {
-				switch format {
-				case "ipv4":
-					if value.number.isIntegral() {
-						ipv4Str := toIPv4(value.number)
-						if ipv4Str != "" {
-							fmt.Printf("%s%*s", separator, ipv4Width, ipv4Str)
-							separator = "  "
-						}
-					}
-				case "rational":
-					numerator := value.number.Rat.Num()
-					denominator := value.number.Rat.Denom()
-					ratStr := fmt.Sprintf("%s/%s", numerator.String(), denominator.String())
-					fmt.Printf("%s%*s", separator, rationalWidth, ratStr)
-					separator = "  "
-				case "factor":
-					if value.number.isIntegral() {
-						factorStr := toFactor(value.number)
-						if factorStr != "" {
-							fmt.Printf("%s%s", separator, factorStr)
-							separator = "  "
-						}
-					}
-				case "ieee32":
-					fmt.Printf("%s%s", separator, toIEEE32(value.number))
-					separator = "  "
-				case "ieee64":
-					fmt.Printf("%s%s", separator, toIEEE64(value.number))
-					separator = "  "
-				}
-			}
-
-			// Add units if present
-			if !value.units.empty() {
-				fmt.Printf(" %s", value.units.String())
-			}
-		}
-
-		fmt.Println()
-	}
-}
-
-// Statistical stack operations
-func (s *Stack) min(replace bool) {
-	if len(s.values) == 0 {
-		die("Stack is empty for 'min', exiting")
-	}
-
-	minVal := s.values[0]
-	for i := 1; i < len(s.values); i++ {
-		// Convert values to compatible units before comparison
-		current := s.values[i]
-		if !minVal.units.compatible(current.units) {
-			die("Incompatible units for 'min': %s vs %s", minVal.units.Name(), current.units.Name())
-		}
-
-		// Convert current to minVal's units for comparison
-		currentConverted := current.apply(minVal.units)
-
-		// Compare the numbers (assuming both are now in same units)
-		if currentConverted.number.Rat.Cmp(minVal.number.Rat) < 0 {
-			minVal = currentConverted
-		}
-	}
-
-	if replace {
-		// Clear stack and push minimum value
-		s.values = []Value{minVal}
-	} else {
-		// Push minimum value onto existing stack
-		s.push(minVal)
-	}
-}
-
-func (s *Stack) max(replace bool) {
-	if len(s.values) == 0 {
-		die("Stack is empty for 'max', exiting")
-	}
-
-	maxVal := s.values[0]
-	for i := 1; i < len(s.values); i++ {
-		// Convert values to compatible units before comparison
-		current := s.values[i]
-		if !maxVal.units.compatible(current.units) {
-			die("Incompatible units for 'max': %s vs %s", maxVal.units.Name(), current.units.Name())
-		}
-
-		// Convert current to maxVal's units for comparison
-		currentConverted := current.apply(maxVal.units)
-
-		// Compare the numbers (assuming both are now in same units)
-		if currentConverted.number.Rat.Cmp(maxVal.number.Rat) > 0 {
-			maxVal = currentConverted
-		}
-	}
-
-	if replace {
-		// Clear stack and push maximum value
-		s.values = []Value{maxVal}
-	} else {
-		// Push maximum value onto existing stack
-		s.push(maxVal)
-	}
-}
-
-func (s *Stack) mean(replace bool) {
-	if len(s.values) == 0 {
-		die("Stack is empty for 'mean', exiting")
-	}
-
-	// All values must have compatible units
-	baseUnit := s.values[0].units
-	sum := s.values[0]
-	originalCount := len(s.values)
-
-	for i := 1; i < len(s.values); i++ {
-		current := s.values[i]
-		if !baseUnit.compatible(current.units) {
-			die("Incompatible units for 'mean': %s vs %s", baseUnit.Name(), current.units.Name())
-		}
-
-		// Convert to base units and add
-		currentConverted := current.apply(baseUnit)
-		sum = sum.binaryOp("+", currentConverted)
-	}
-
-	// Divide by count
-	count := newNumber(originalCount)
-	countVal := Value{number: count}
-	result := sum.binaryOp("/", countVal)
-
-	if replace {
-		// Clear stack and push mean
-		s.values = []Value{result}
-	} else {
-		// Push mean onto existing stack
-		s.push(result)
-	}
-}
-
-func (s *Stack) stackSize(replace bool) {
-	// Get the size of the stack
-	size := newNumber(len(s.values))
-	sizeVal := Value{number: size}
-
-	if replace {
-		// Replace stack with size
-		s.values = []Value{sizeVal}
-	} else {
-		// Push size onto existing stack
-		s.push(sizeVal)
-	}
-}
-
-func (s *Stack) printStats() {
-	if len(s.values) == 0 {
-		fmt.Printf("Statistics: no values\n")
-		return
-	}
-
-	// Get all values in compatible units for statistics
-	baseUnit := s.values[0].units
-	var convertedValues []*Number
-
-	for i, val := range s.values {
-		if !baseUnit.compatible(val.units) {
-			fmt.Printf("Statistics: incompatible units %s vs %s - cannot compute statistics\n", baseUnit.Name(), val.units.Name())
-			return
-		}
-
-		if i == 0 {
-			convertedValues = append(convertedValues, val.number)
-		} else {
-			converted := val.apply(baseUnit)
-			convertedValues = append(convertedValues, converted.number)
-		}
-	}
-
-	// Calculate statistics
-	count := len(convertedValues)
-
-	// Sum
-	sum := convertedValues[0]
-	for i := 1; i < len(convertedValues); i++ {
-		sum = add(sum, convertedValues[i])
-	}
-
-	// Mean
-	countNum := newNumber(count)
-	mean := div(sum, countNum)
-
-	// Min and Max
-	min := convertedValues[0]
-	max := convertedValues[0]
-	for i := 1; i < len(convertedValues); i++ {
-		if convertedValues[i].Rat.Cmp(min.Rat) < 0 {
-			min = convertedValues[i]
-		}
-		if convertedValues[i].Rat.Cmp(max.Rat) > 0 {
-			max = convertedValues[i]
-		}
-	}
-
-	// Range
-	rangeVal := sub(max, min)
-
-	// Format units string
-	unitsStr := baseUnit.String()
-	if unitsStr != "" {
-		unitsStr = " " + unitsStr
-	}
-
-	// Print statistics
-	fmt.Printf("Statistics:\n")
-	fmt.Printf("  count: %d\n", count)
-	fmt.Printf("  sum:   %s%s\n", sum.String(), unitsStr)
-	fmt.Printf("  min:   %s%s\n", min.String(), unitsStr)
-	fmt.Printf("  max:   %s%s\n", max.String(), unitsStr)
-	fmt.Printf("  range: %s%s\n", rangeVal.String(), unitsStr)
-	fmt.Printf("  mean:  %s%s\n", mean.String(), unitsStr)
-}
@@ -97,10 +97,10 @@ func TestTimeParsingInvalidFormats(t *testing.T) {
 			if err == nil {
 				t.Errorf("Expected error for invalid time format %q, but got none", input)
 			}
-			// The error output should contain "Unrecognized argument"
+			// The error output should contain "unrecognized argument"
 			outputStr := string(output)
-			if !strings.Contains(outputStr, "Unrecognized argument") {
-				t.Errorf("Expected 'Unrecognized argument' in output for %q, got: %q", input, outputStr)
+			if !strings.Contains(outputStr, "unrecognized argument") {
+				t.Errorf("Expected 'unrecognized argument' in output for %q, got: %q", input, outputStr)
 			}
 		})
 	}